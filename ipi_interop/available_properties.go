@@ -39,7 +39,17 @@ import "unsafe"
 func GetAvailablePropertyNames(manager *ResourceManager) []string {
 	cDataSet := (*C.DataSetIpi)(unsafe.Pointer(C.DataSetGet(manager.CPtr)))
 	defer C.DataSetRelease((*C.DataSetBase)(unsafe.Pointer(cDataSet)))
+	return availablePropertyNames(cDataSet)
+}
+
+// AvailablePropertyNames is the same as GetAvailablePropertyNames for the
+// dataset the results were created from, which may no longer be the
+// manager's current dataset if it has since been reloaded.
+func (r *ResultsIpi) AvailablePropertyNames() []string {
+	return availablePropertyNames((*C.DataSetIpi)(r.CPtr.b.dataSet))
+}
 
+func availablePropertyNames(cDataSet *C.DataSetIpi) []string {
 	count := int(cDataSet.b.b.available.count)
 	names := make([]string, 0, count)
 	for i := 0; i < count; i++ {
@@ -52,3 +62,60 @@ func GetAvailablePropertyNames(manager *ResourceManager) []string {
 	}
 	return names
 }
+
+// GetPropertyValueTypes returns the declared value type of every property
+// available in the dataset, keyed by property name. The type is read from the
+// properties collection the same way the weighted-value decoding resolves
+// whether a property is weighted. Properties whose type cannot be read are
+// left out of the map.
+func GetPropertyValueTypes(manager *ResourceManager) map[string]PropertyValueType {
+	cDataSet := (*C.DataSetIpi)(unsafe.Pointer(C.DataSetGet(manager.CPtr)))
+	defer C.DataSetRelease((*C.DataSetBase)(unsafe.Pointer(cDataSet)))
+	return propertyValueTypes(cDataSet)
+}
+
+// PropertyValueTypes is the same as GetPropertyValueTypes for the dataset the
+// results were created from.
+func (r *ResultsIpi) PropertyValueTypes() map[string]PropertyValueType {
+	return propertyValueTypes((*C.DataSetIpi)(r.CPtr.b.dataSet))
+}
+
+func propertyValueTypes(cDataSet *C.DataSetIpi) map[string]PropertyValueType {
+	exception := NewException()
+	defer exception.Free()
+
+	count := int(cDataSet.b.b.available.count)
+	types := make(map[string]PropertyValueType, count)
+	for i := 0; i < count; i++ {
+		res := C.fiftyoneDegreesPropertiesGetNameFromRequiredIndex(
+			cDataSet.b.b.available, C.int(i),
+		)
+		if res == nil {
+			continue
+		}
+
+		propertyIndex := C.fiftyoneDegreesPropertiesGetPropertyIndexFromRequiredIndex(
+			cDataSet.b.b.available, C.int(i))
+		if propertyIndex < 0 {
+			continue
+		}
+
+		exception.Clear()
+		valueType := C.fiftyoneDegreesPropertyGetValueType(
+			cDataSet.properties, C.uint32_t(propertyIndex), exception.CPtr)
+		if !exception.IsOkay() {
+			continue
+		}
+
+		types[C.GoString(&res.value)] = PropertyValueType(valueType)
+	}
+	return types
+}
+
+// DataSetID identifies the dataset the results were created from. Results
+// created from the same dataset have the same ID, and the ID changes when the
+// manager is reloaded. An ID can be reused by a later dataset once every
+// ResultsIpi created from the dataset it identified has been freed.
+func (r *ResultsIpi) DataSetID() uintptr {
+	return uintptr(unsafe.Pointer(r.CPtr.b.dataSet))
+}
@@ -0,0 +1,146 @@
+/* *********************************************************************
+ * This Original Work is copyright of 51 Degrees Mobile Experts Limited.
+ * Copyright 2019 51 Degrees Mobile Experts Limited, 5 Charlotte Close,
+ * Caversham, Reading, Berkshire, United Kingdom RG4 7BY.
+ *
+ * This Original Work is licensed under the European Union Public Licence (EUPL)
+ * v.1.2 and is subject to its terms as set out below.
+ *
+ * If a copy of the EUPL was not distributed with this file, You can obtain
+ * one at https://opensource.org/licenses/EUPL-1.2.
+ *
+ * The 'Compatible Licences' set out in the Appendix to the EUPL (as may be
+ * amended by the European Commission) shall be deemed incompatible for
+ * the purposes of the Work and the provisions of the compatibility
+ * clause in Article 5 of the EUPL shall not apply.
+ *
+ * If using the Work as, or as part of, a network application, by
+ * including the attribution notice(s) required under Article 5 of the EUPL
+ * in the end user terms of the application under an appropriate heading,
+ * such notice(s) shall fulfill the requirements of that article.
+ * ********************************************************************* */
+
+package ipi_interop
+
+/*
+#include <stdlib.h>
+#include "ip-intelligence-cxx.h"
+
+// Defined in property_metadata.go.
+char* copyString(
+	fiftyoneDegreesCollection *strings,
+	const fiftyoneDegreesString *value,
+	fiftyoneDegreesCollectionItem *item);
+
+// componentInfo describes a component. The strings, and the array of header
+// names, are copies which must be freed.
+typedef struct component_info_t {
+	char *name;
+	char **headers;
+	uint32_t headerCount;
+	int32_t defaultProfileOffset;
+	byte id;
+} componentInfo;
+
+// getComponentInfo fills info with the description of the component at index
+// in the components list of the data set, returning false if there is no such
+// component.
+static bool getComponentInfo(
+	fiftyoneDegreesDataSetIpi *dataSet,
+	uint32_t index,
+	componentInfo *info,
+	fiftyoneDegreesException *exception) {
+	fiftyoneDegreesCollectionItem item;
+	fiftyoneDegreesComponent *component;
+	fiftyoneDegreesHeaderPtrs *headers;
+	uint32_t i;
+
+	if (index >= dataSet->componentsList.count) {
+		return false;
+	}
+	component = (fiftyoneDegreesComponent*)
+		dataSet->componentsList.items[index].data.ptr;
+	if (component == NULL) {
+		return false;
+	}
+
+	info->id = component->componentId;
+	fiftyoneDegreesDataReset(&item.data);
+	info->name = copyString(dataSet->strings, fiftyoneDegreesComponentGetName(
+		dataSet->strings, component, &item, exception), &item);
+	info->defaultProfileOffset = component->defaultProfileOffset;
+
+	info->headers = NULL;
+	info->headerCount = 0;
+	if (dataSet->b.b.uniqueHeaders == NULL || component->keyValuesCount == 0) {
+		return true;
+	}
+	headers = fiftyoneDegreesComponentGetHeaders(
+		component, dataSet->b.b.uniqueHeaders, exception);
+	if (headers == NULL) {
+		return true;
+	}
+	info->headers = (char**)calloc(headers->count, sizeof(char*));
+	if (info->headers != NULL) {
+		for (i = 0; i < headers->count; i++) {
+			if (headers->items[i] != NULL && headers->items[i]->name != NULL) {
+				info->headers[info->headerCount++] = strdup(headers->items[i]->name);
+			}
+		}
+	}
+	fiftyoneDegreesFree(headers);
+	return true;
+}
+*/
+import "C"
+import "unsafe"
+
+// Component describes a component of the data file, which groups the
+// properties relating to one aspect of an IP address, e.g. its location or
+// the network it belongs to. Every profile belongs to one component.
+type Component struct {
+	ID                   byte     // Unique id of the component in the data file
+	Name                 string   // Name of the component, e.g. Location
+	DefaultProfileOffset uint32   // Offset of the profile used when no other profile matches, see GetProfileValues
+	Headers              []string // Evidence headers the component considers, if any
+}
+
+// GetComponents returns the components of the manager's current data set in
+// the order of the data file.
+func GetComponents(manager *ResourceManager) []Component {
+	cDataSet := (*C.DataSetIpi)(unsafe.Pointer(C.DataSetGet(manager.CPtr)))
+	defer C.DataSetRelease((*C.DataSetBase)(unsafe.Pointer(cDataSet)))
+
+	return components(cDataSet)
+}
+
+// components returns the components of the data set. The caller must hold a
+// reference to the data set.
+func components(cDataSet *C.DataSetIpi) []Component {
+	exception := NewException()
+	defer exception.Free()
+
+	count := int(cDataSet.componentsList.count)
+	components := make([]Component, 0, count)
+	for i := 0; i < count; i++ {
+		var info C.componentInfo
+		exception.Clear()
+		if !C.getComponentInfo(cDataSet, C.uint32_t(i), &info, exception.CPtr) {
+			continue
+		}
+
+		component := Component{
+			ID:                   byte(info.id),
+			Name:                 takeString(info.name),
+			DefaultProfileOffset: uint32(info.defaultProfileOffset),
+		}
+		if info.headers != nil {
+			for _, header := range unsafe.Slice(info.headers, info.headerCount) {
+				component.Headers = append(component.Headers, takeString(header))
+			}
+			C.free(unsafe.Pointer(info.headers))
+		}
+		components = append(components, component)
+	}
+	return components
+}
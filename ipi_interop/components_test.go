@@ -0,0 +1,54 @@
+/* *********************************************************************
+ * This Original Work is copyright of 51 Degrees Mobile Experts Limited.
+ * Copyright 2019 51 Degrees Mobile Experts Limited, 5 Charlotte Close,
+ * Caversham, Reading, Berkshire, United Kingdom RG4 7BY.
+ *
+ * This Original Work is licensed under the European Union Public Licence (EUPL)
+ * v.1.2 and is subject to its terms as set out below.
+ *
+ * If a copy of the EUPL was not distributed with this file, You can obtain
+ * one at https://opensource.org/licenses/EUPL-1.2.
+ *
+ * The 'Compatible Licences' set out in the Appendix to the EUPL (as may be
+ * amended by the European Commission) shall be deemed incompatible for
+ * the purposes of the Work and the provisions of the compatibility
+ * clause in Article 5 of the EUPL shall not apply.
+ *
+ * If using the Work as, or as part of, a network application, by
+ * including the attribution notice(s) required under Article 5 of the EUPL
+ * in the end user terms of the application under an appropriate heading,
+ * such notice(s) shall fulfill the requirements of that article.
+ * ********************************************************************* */
+
+package ipi_interop
+
+import "testing"
+
+func TestGetComponents(t *testing.T) {
+	manager := newTestManager(t)
+
+	components := GetComponents(manager)
+	if len(components) == 0 {
+		t.Fatal("Expected components, got none")
+	}
+	ids := make(map[byte]bool)
+	for _, component := range components {
+		if component.Name == "" {
+			t.Errorf("Expected component %d to have a name", component.ID)
+		}
+		if ids[component.ID] {
+			t.Errorf("Expected component ids to be unique, got %d twice", component.ID)
+		}
+		ids[component.ID] = true
+	}
+}
+
+func TestGetComponents_DefaultProfileOffset(t *testing.T) {
+	manager := newTestManager(t)
+
+	for _, component := range GetComponents(manager) {
+		if _, err := GetProfileValues(manager, component.DefaultProfileOffset); err != nil {
+			t.Errorf("Expected the default profile of %s, got %v", component.Name, err)
+		}
+	}
+}
@@ -24,6 +24,7 @@ package ipi_interop
 //#include <string.h>
 //#include "ip-intelligence-cxx.h"
 import "C"
+import "fmt"
 
 // Performance Profile
 type PerformanceProfile int
@@ -37,6 +38,25 @@ const (
 	InMemory
 )
 
+// String returns the name of the performance profile.
+func (p PerformanceProfile) String() string {
+	switch p {
+	case Default:
+		return "Default"
+	case LowMemory:
+		return "LowMemory"
+	case BalancedTemp:
+		return "BalancedTemp"
+	case Balanced:
+		return "Balanced"
+	case HighPerformance:
+		return "HighPerformance"
+	case InMemory:
+		return "InMemory"
+	}
+	return fmt.Sprintf("PerformanceProfile(%d)", int(p))
+}
+
 // ConfigIpi wraps around pointer to a value of C ConfigIpi structure
 type ConfigIpi struct {
 	CPtr *C.ConfigIpi
@@ -86,6 +106,13 @@ func (config *ConfigIpi) SetConcurrency(concurrency uint16) {
 
 }
 
+// Concurrency returns the expected concurrent requests the collections have
+// been configured for. Zero means the collections are fully loaded into memory
+// and do not limit concurrent access.
+func (config *ConfigIpi) Concurrency() uint16 {
+	return uint16(config.CPtr.strings.concurrency)
+}
+
 // PerformanceProfile get the configured performance profile
 func (config *ConfigIpi) PerformanceProfile() PerformanceProfile {
 	return config.perf
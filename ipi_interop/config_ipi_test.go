@@ -168,3 +168,59 @@ func TestNewConfigIpi(t *testing.T) {
 //		})
 //	}
 //}
+
+func TestConfigIpi_Concurrency(t *testing.T) {
+	tests := []struct {
+		name        string
+		profile     PerformanceProfile
+		concurrency uint16
+		set         bool
+		want        uint16
+	}{
+		{
+			name:    "InMemory profile has no concurrency limit",
+			profile: InMemory,
+			want:    0,
+		},
+		{
+			name:        "explicit concurrency",
+			profile:     Balanced,
+			concurrency: 4,
+			set:         true,
+			want:        4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := NewConfigIpi(tt.profile)
+			if tt.set {
+				config.SetConcurrency(tt.concurrency)
+			}
+			if got := config.Concurrency(); got != tt.want {
+				t.Errorf("Concurrency() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPerformanceProfile_String(t *testing.T) {
+	tests := []struct {
+		profile PerformanceProfile
+		want    string
+	}{
+		{Default, "Default"},
+		{LowMemory, "LowMemory"},
+		{BalancedTemp, "BalancedTemp"},
+		{Balanced, "Balanced"},
+		{HighPerformance, "HighPerformance"},
+		{InMemory, "InMemory"},
+		{PerformanceProfile(999), "PerformanceProfile(999)"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.profile.String(); got != tt.want {
+			t.Errorf("Expected %q, got %q", tt.want, got)
+		}
+	}
+}
@@ -26,4 +26,11 @@ package ipi_interop
 const (
 	ErrSHPropertyIncorrectFormat = "'SetHeader' property name is not in correct format."
 	ErrNoMatch                   = "No match found."
+	ErrEvidenceKeyNoPrefix       = "evidence key '%s' does not start with a known prefix."
+	ErrDecodeTarget              = "decode target must be a non-nil pointer to a struct, got %T."
+	ErrDataEmpty                 = "data file is empty."
+	ErrProfileNotFound           = "no profile at offset %d."
+	ErrPropertyNotFound          = "property '%s' not found."
+	ErrInvalidDate               = "invalid date %d-%02d-%02d in the data file header."
+	ErrReloadConfig              = "reloads keep the config and properties the manager was initialised with, which differ from those given."
 )
@@ -0,0 +1,155 @@
+/* *********************************************************************
+ * This Original Work is copyright of 51 Degrees Mobile Experts Limited.
+ * Copyright 2019 51 Degrees Mobile Experts Limited, 5 Charlotte Close,
+ * Caversham, Reading, Berkshire, United Kingdom RG4 7BY.
+ *
+ * This Original Work is licensed under the European Union Public Licence (EUPL)
+ * v.1.2 and is subject to its terms as set out below.
+ *
+ * If a copy of the EUPL was not distributed with this file, You can obtain
+ * one at https://opensource.org/licenses/EUPL-1.2.
+ *
+ * The 'Compatible Licences' set out in the Appendix to the EUPL (as may be
+ * amended by the European Commission) shall be deemed incompatible for
+ * the purposes of the Work and the provisions of the compatibility
+ * clause in Article 5 of the EUPL shall not apply.
+ *
+ * If using the Work as, or as part of, a network application, by
+ * including the attribution notice(s) required under Article 5 of the EUPL
+ * in the end user terms of the application under an appropriate heading,
+ * such notice(s) shall fulfill the requirements of that article.
+ * ********************************************************************* */
+
+package ipi_interop
+
+/*
+#include <stdlib.h>
+#include "ip-intelligence-cxx.h"
+
+// dataSetString returns a copy of the string at offset in the strings
+// collection of the data set, which the caller must free, or NULL if it cannot
+// be read.
+static char* dataSetString(fiftyoneDegreesDataSetIpi *dataSet, int32_t offset, fiftyoneDegreesException *exception) {
+	char *copy = NULL;
+	fiftyoneDegreesCollectionItem item;
+	const fiftyoneDegreesString *value;
+	if (offset < 0) {
+		return NULL;
+	}
+	fiftyoneDegreesDataReset(&item.data);
+	value = fiftyoneDegreesStringGet(dataSet->strings, (uint32_t)offset, &item, exception);
+	if (value != NULL) {
+		copy = strdup(&value->value);
+		FIFTYONE_DEGREES_COLLECTION_RELEASE(dataSet->strings, &item);
+	}
+	return copy;
+}
+
+// The offsets are not aligned in the packed header, so cannot be read in Go.
+static int32_t dataSetNameOffset(fiftyoneDegreesDataSetIpi *dataSet) {
+	return dataSet->header.nameOffset;
+}
+
+static int32_t dataSetFormatOffset(fiftyoneDegreesDataSetIpi *dataSet) {
+	return dataSet->header.formatOffset;
+}
+*/
+import "C"
+import (
+	"fmt"
+	"os"
+	"time"
+	"unsafe"
+)
+
+// DataSetInfo describes a data set and the data file it was loaded from.
+type DataSetInfo struct {
+	Published          time.Time          // Date the data file was published
+	NextUpdate         time.Time          // Date the next data file will be available
+	Version            string             // Version of the data file format, e.g. 4.5.0.0
+	Name               string             // Name of the data file, which is the product and tier, e.g. Lite
+	Format             string             // Format of the data file
+	FilePath           string             // Path of the file the data set was loaded from, empty if loaded from memory
+	FileSize           int64              // Size of the data file in bytes
+	PropertyCount      int                // Number of properties available in the data set
+	IPv4               bool               // Whether the data set holds IPv4 ranges
+	IPv6               bool               // Whether the data set holds IPv6 ranges
+	PerformanceProfile PerformanceProfile // Performance profile the data set was loaded with
+}
+
+// GetDataSetInfo returns the information in the header of the manager's
+// current data set. The file size is read from the file system, so is zero
+// for a data set loaded from memory, and the performance profile is not known
+// to the data set so is left as Default.
+func GetDataSetInfo(manager *ResourceManager) DataSetInfo {
+	cDataSet := (*C.DataSetIpi)(unsafe.Pointer(C.DataSetGet(manager.CPtr)))
+	defer C.DataSetRelease((*C.DataSetBase)(unsafe.Pointer(cDataSet)))
+
+	header := &cDataSet.header
+	info := DataSetInfo{
+		Published:  toTime(header.published),
+		NextUpdate: toTime(header.nextUpdate),
+		Version: fmt.Sprintf("%d.%d.%d.%d",
+			int32(header.versionMajor), int32(header.versionMinor),
+			int32(header.versionBuild), int32(header.versionRevision)),
+		Name:          dataSetString(cDataSet, C.dataSetNameOffset(cDataSet)),
+		Format:        dataSetString(cDataSet, C.dataSetFormatOffset(cDataSet)),
+		PropertyCount: int(cDataSet.b.b.available.count),
+	}
+
+	// The file name is empty for a data set loaded from memory.
+	info.FilePath = C.GoString(&cDataSet.b.b.fileName[0])
+	if info.FilePath != "" {
+		if stat, err := os.Stat(info.FilePath); err == nil {
+			info.FileSize = stat.Size()
+		}
+	}
+
+	if graphs := cDataSet.graphsArray; graphs != nil && graphs.count > 0 {
+		for _, graph := range unsafe.Slice(graphs.items, graphs.count) {
+			switch C.fiftyoneDegreesIpType(graph.info.version) {
+			case C.IP_TYPE_IPV4:
+				info.IPv4 = true
+			case C.IP_TYPE_IPV6:
+				info.IPv6 = true
+			}
+		}
+	}
+
+	return info
+}
+
+// dataSetString returns the string at offset in the strings collection of the
+// data set, or an empty string if it cannot be read.
+func dataSetString(cDataSet *C.DataSetIpi, offset C.int32_t) string {
+	exception := NewException()
+	defer exception.Free()
+
+	cString := C.dataSetString(cDataSet, offset, exception.CPtr)
+	if cString == nil {
+		return ""
+	}
+	defer C.free(unsafe.Pointer(cString))
+	return C.GoString(cString)
+}
+
+// firstPublished is when the first data file of this format was published. An
+// earlier date in a header, such as the year 0 of a zeroed one, is not a real
+// date.
+var firstPublished = time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// headerDate returns the date with the year, month and day of a data set
+// header, or an error matching ErrCorruptData if they are not a real date on
+// or after firstPublished.
+func headerDate(year, month, day int) (time.Time, error) {
+	date := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	if date.Year() != year || int(date.Month()) != month || date.Day() != day || date.Before(firstPublished) {
+		return time.Time{}, &StatusError{Code: StatusCorruptData, Message: fmt.Sprintf(ErrInvalidDate, year, month, day)}
+	}
+	return date, nil
+}
+
+// toTime converts a date from a data set header to a time in UTC.
+func toTime(date C.fiftyoneDegreesDate) time.Time {
+	return time.Date(int(date.year), time.Month(date.month), int(date.day), 0, 0, 0, 0, time.UTC)
+}
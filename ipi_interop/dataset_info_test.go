@@ -0,0 +1,118 @@
+/* *********************************************************************
+ * This Original Work is copyright of 51 Degrees Mobile Experts Limited.
+ * Copyright 2019 51 Degrees Mobile Experts Limited, 5 Charlotte Close,
+ * Caversham, Reading, Berkshire, United Kingdom RG4 7BY.
+ *
+ * This Original Work is licensed under the European Union Public Licence (EUPL)
+ * v.1.2 and is subject to its terms as set out below.
+ *
+ * If a copy of the EUPL was not distributed with this file, You can obtain
+ * one at https://opensource.org/licenses/EUPL-1.2.
+ *
+ * The 'Compatible Licences' set out in the Appendix to the EUPL (as may be
+ * amended by the European Commission) shall be deemed incompatible for
+ * the purposes of the Work and the provisions of the compatibility
+ * clause in Article 5 of the EUPL shall not apply.
+ *
+ * If using the Work as, or as part of, a network application, by
+ * including the attribution notice(s) required under Article 5 of the EUPL
+ * in the end user terms of the application under an appropriate heading,
+ * such notice(s) shall fulfill the requirements of that article.
+ * ********************************************************************* */
+
+package ipi_interop
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestGetDataSetInfo(t *testing.T) {
+	manager := newTestManager(t)
+	filePath := os.Getenv("DATA_FILE")
+	stat, err := os.Stat(filePath)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	info := GetDataSetInfo(manager)
+	if info.Published.IsZero() || info.Published.Year() < 2019 {
+		t.Errorf("Expected a published date, got %v", info.Published)
+	}
+	if info.Version == "" || info.Version == "0.0.0.0" {
+		t.Errorf("Expected a version, got %q", info.Version)
+	}
+	if info.Name == "" {
+		t.Error("Expected a name, got none")
+	}
+	if info.FilePath != filePath {
+		t.Errorf("Expected file path %q, got %q", filePath, info.FilePath)
+	}
+	if info.FileSize != stat.Size() {
+		t.Errorf("Expected file size %d, got %d", stat.Size(), info.FileSize)
+	}
+	if info.PropertyCount != len(GetPropertyMetadata(manager)) {
+		t.Errorf("Expected %d properties, got %d", len(GetPropertyMetadata(manager)), info.PropertyCount)
+	}
+	if !info.IPv4 && !info.IPv6 {
+		t.Error("Expected the data set to hold IPv4 or IPv6 ranges")
+	}
+}
+
+func TestGetDataSetInfo_FromMemory(t *testing.T) {
+	fileManager := newTestManager(t)
+	data, err := os.ReadFile(os.Getenv("DATA_FILE"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	manager := NewResourceManager()
+	defer manager.Free()
+	if err := InitManagerFromMemory(manager, *NewConfigIpi(InMemory), "", data); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	info, want := GetDataSetInfo(manager), GetDataSetInfo(fileManager)
+	if info.FilePath != "" || info.FileSize != 0 {
+		t.Errorf("Expected no file path or size, got %q and %d", info.FilePath, info.FileSize)
+	}
+	if !info.Published.Equal(want.Published) || info.Version != want.Version || info.Name != want.Name {
+		t.Errorf("Expected the header of the data file %+v, got %+v", want, info)
+	}
+}
+
+func TestHeaderDate(t *testing.T) {
+	tests := []struct {
+		name    string
+		year    int
+		month   int
+		day     int
+		want    time.Time
+		wantErr bool
+	}{
+		{name: "valid", year: 2025, month: 6, day: 30, want: time.Date(2025, time.June, 30, 0, 0, 0, 0, time.UTC)},
+		{name: "first published", year: 2019, month: 1, day: 1, want: firstPublished},
+		{name: "zeroed", wantErr: true},
+		{name: "before the format", year: 2018, month: 12, day: 31, wantErr: true},
+		{name: "month out of range", year: 2025, month: 13, day: 1, wantErr: true},
+		{name: "day out of range", year: 2025, month: 2, day: 30, wantErr: true},
+		{name: "negative year", year: -1, month: 1, day: 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := headerDate(tt.year, tt.month, tt.day)
+			if tt.wantErr {
+				if !errors.Is(err, ErrCorruptData) {
+					t.Errorf("Expected %v, got %v", ErrCorruptData, err)
+				}
+				return
+			}
+			if err != nil || !got.Equal(tt.want) {
+				t.Errorf("Expected %v, got %v and %v", tt.want, got, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,334 @@
+/* *********************************************************************
+ * This Original Work is copyright of 51 Degrees Mobile Experts Limited.
+ * Copyright 2019 51 Degrees Mobile Experts Limited, 5 Charlotte Close,
+ * Caversham, Reading, Berkshire, United Kingdom RG4 7BY.
+ *
+ * This Original Work is licensed under the European Union Public Licence (EUPL)
+ * v.1.2 and is subject to its terms as set out below.
+ *
+ * If a copy of the EUPL was not distributed with this file, You can obtain
+ * one at https://opensource.org/licenses/EUPL-1.2.
+ *
+ * The 'Compatible Licences' set out in the Appendix to the EUPL (as may be
+ * amended by the European Commission) shall be deemed incompatible for
+ * the purposes of the Work and the provisions of the compatibility
+ * clause in Article 5 of the EUPL shall not apply.
+ *
+ * If using the Work as, or as part of, a network application, by
+ * including the attribution notice(s) required under Article 5 of the EUPL
+ * in the end user terms of the application under an appropriate heading,
+ * such notice(s) shall fulfill the requirements of that article.
+ * ********************************************************************* */
+package ipi_interop
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"sync"
+)
+
+// decodeTag is the struct tag which names the property a field is filled from.
+const decodeTag = "ipi"
+
+// Weighted is a single value of a property together with its weight, for use
+// as the element type of a []Weighted[T] field filled by Values.Decode.
+type Weighted[T any] struct {
+	Value  T
+	Weight float64
+}
+
+// weightedElem is implemented by every Weighted[T] so the decoder can
+// recognise []Weighted[T] fields whatever T is.
+type weightedElem interface {
+	weightedValueType() reflect.Type
+}
+
+func (Weighted[T]) weightedValueType() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+// DecodeError is returned by Values.Decode when the value of a property
+// cannot be converted to the type of the field it is decoded into.
+type DecodeError struct {
+	// Field is the name of the struct field
+	Field string
+	// Property is the name of the property in the field's tag
+	Property string
+	// Type is the type the value was converted to
+	Type reflect.Type
+	// Value is the value which could not be converted
+	Value interface{}
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf(
+		"cannot decode property '%s' value %v of type %T into field %s of type %s",
+		e.Property, e.Value, e.Value, e.Field, e.Type)
+}
+
+// fieldKind is how a field is filled from the values of its property.
+type fieldKind int
+
+const (
+	// fieldValue is filled from the first value and must have one
+	fieldValue fieldKind = iota
+	// fieldPointer is filled from the first value, or left nil if there is none
+	fieldPointer
+	// fieldWeighted is a []Weighted[T] filled from every value
+	fieldWeighted
+)
+
+// fieldDecoder describes how one tagged field of a struct is filled.
+type fieldDecoder struct {
+	name     string
+	property string
+	index    []int
+	kind     fieldKind
+	// valueType is the type each value is converted to: the field type, the
+	// pointed to type, or T of Weighted[T]
+	valueType reflect.Type
+}
+
+// decoders caches the field decoders of each struct type passed to Decode,
+// so the struct tags are only parsed once per type.
+var decoders sync.Map // reflect.Type → []fieldDecoder
+
+// Decode fills the fields of the struct pointed to by dst from the values.
+// Each field with an `ipi:"PropertyName"` tag is filled from that property,
+// and fields without a tag, or with the tag "-", are left untouched. The Go
+// type of the field decides the conversion:
+//
+//   - string, bool, integer and floating point fields take the first value,
+//     converting between numeric types. A string field also accepts any
+//     value with a String method, such as netip.Addr and Geometry.
+//   - Any other type, such as netip.Addr, Geometry or interface{}, takes the
+//     first value if it is assignable to the field.
+//   - *T takes the first value as for T, and is left nil if the property has
+//     no values.
+//   - []Weighted[T] takes every value with its weight.
+//
+// Conversions between numeric types must not lose the value: a floating point
+// value with a fractional part cannot fill an integer field, nor can a value
+// out of the range of the field's type. Fields promoted from an embedded
+// struct pointer which is nil have it allocated, unless the embedded field is
+// unexported, in which case they are left alone.
+//
+// A field which is not a pointer or a []Weighted[T] must have a value.
+// Decode fills every field it can and returns the errors for the others
+// joined together: a *NoValueError for a missing value and a *DecodeError for
+// a value which cannot be converted.
+func (v Values) Decode(dst interface{}) error {
+	target := reflect.ValueOf(dst)
+	if target.Kind() != reflect.Pointer || target.IsNil() || target.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf(ErrDecodeTarget, dst)
+	}
+	target = target.Elem()
+
+	var errs []error
+	for _, field := range cachedFieldDecoders(target.Type()) {
+		value, ok := fieldByIndex(target, field.index)
+		if !ok {
+			continue
+		}
+		if err := field.decode(value, v[field.property]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// fieldByIndex returns the nested field of the struct v with the index, as
+// reflect.Value.FieldByIndex does, but allocates the embedded struct pointers
+// the field is promoted through if they are nil. It returns false if one of
+// them is nil and cannot be allocated, as the embedded field is unexported.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// cachedFieldDecoders returns the field decoders for the struct type,
+// building and caching them on first use.
+func cachedFieldDecoders(t reflect.Type) []fieldDecoder {
+	if cached, ok := decoders.Load(t); ok {
+		return cached.([]fieldDecoder)
+	}
+	fields := newFieldDecoders(t)
+	cached, _ := decoders.LoadOrStore(t, fields)
+	return cached.([]fieldDecoder)
+}
+
+// newFieldDecoders builds a decoder for every exported, tagged field of the
+// struct type.
+func newFieldDecoders(t reflect.Type) []fieldDecoder {
+	var fields []fieldDecoder
+	for _, f := range reflect.VisibleFields(t) {
+		property, ok := f.Tag.Lookup(decodeTag)
+		if !ok || property == "" || property == "-" || !f.IsExported() {
+			continue
+		}
+
+		field := fieldDecoder{
+			name:      f.Name,
+			property:  property,
+			index:     f.Index,
+			kind:      fieldValue,
+			valueType: f.Type,
+		}
+		switch f.Type.Kind() {
+		case reflect.Pointer:
+			field.kind = fieldPointer
+			field.valueType = f.Type.Elem()
+		case reflect.Slice:
+			if elem, ok := reflect.Zero(f.Type.Elem()).Interface().(weightedElem); ok {
+				field.kind = fieldWeighted
+				field.valueType = elem.weightedValueType()
+			}
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// decode fills the field from the values of its property.
+func (f *fieldDecoder) decode(field reflect.Value, values []*WeightedValue) error {
+	if len(values) == 0 {
+		if f.kind != fieldValue {
+			field.SetZero()
+			return nil
+		}
+		return &NoValueError{Property: f.property, Reason: NoValueReasonNoResultForProperty}
+	}
+
+	switch f.kind {
+	case fieldPointer:
+		value, err := f.convert(values[0].Value)
+		if err != nil {
+			return err
+		}
+		ptr := reflect.New(f.valueType)
+		ptr.Elem().Set(value)
+		field.Set(ptr)
+
+	case fieldWeighted:
+		slice := reflect.MakeSlice(field.Type(), len(values), len(values))
+		for i, wv := range values {
+			value, err := f.convert(wv.Value)
+			if err != nil {
+				return err
+			}
+			elem := slice.Index(i)
+			elem.Field(0).Set(value)
+			elem.Field(1).SetFloat(wv.Weight)
+		}
+		field.Set(slice)
+
+	default:
+		value, err := f.convert(values[0].Value)
+		if err != nil {
+			return err
+		}
+		field.Set(value)
+	}
+	return nil
+}
+
+// convert returns the value as the field's value type.
+func (f *fieldDecoder) convert(value interface{}) (reflect.Value, error) {
+	source := reflect.ValueOf(value)
+	if source.IsValid() {
+		if source.Type().AssignableTo(f.valueType) {
+			result := reflect.New(f.valueType).Elem()
+			result.Set(source)
+			return result, nil
+		}
+		if isNumericKind(source.Kind()) && isNumericKind(f.valueType.Kind()) {
+			if result, ok := convertNumber(source, f.valueType); ok {
+				return result, nil
+			}
+		}
+		if f.valueType.Kind() == reflect.String {
+			if s, ok := value.(fmt.Stringer); ok {
+				return reflect.ValueOf(s.String()).Convert(f.valueType), nil
+			}
+		}
+	}
+	return reflect.Value{}, &DecodeError{
+		Field:    f.name,
+		Property: f.property,
+		Type:     f.valueType,
+		Value:    value,
+	}
+}
+
+// convertNumber converts a numeric value to the numeric type t. It fails,
+// rather than truncate or wrap the value, for a floating point value with a
+// fractional part converted to an integer type, and for a value out of the
+// range of t.
+func convertNumber(source reflect.Value, t reflect.Type) (reflect.Value, bool) {
+	result := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.Float32, reflect.Float64:
+		f := source.Convert(reflect.TypeOf(float64(0))).Float()
+		if result.OverflowFloat(f) {
+			return reflect.Value{}, false
+		}
+		result.SetFloat(f)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := integerValue(source)
+		if !ok || !i.IsInt64() || result.OverflowInt(i.Int64()) {
+			return reflect.Value{}, false
+		}
+		result.SetInt(i.Int64())
+	default:
+		i, ok := integerValue(source)
+		if !ok || !i.IsUint64() || result.OverflowUint(i.Uint64()) {
+			return reflect.Value{}, false
+		}
+		result.SetUint(i.Uint64())
+	}
+	return result, true
+}
+
+// integerValue returns a numeric value as an integer, or false for a floating
+// point value which is not a whole number.
+func integerValue(source reflect.Value) (*big.Int, bool) {
+	switch source.Kind() {
+	case reflect.Float32, reflect.Float64:
+		f := source.Float()
+		if f != math.Trunc(f) || math.IsInf(f, 0) {
+			return nil, false
+		}
+		i, _ := big.NewFloat(f).Int(nil)
+		return i, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return new(big.Int).SetUint64(source.Uint()), true
+	default:
+		return big.NewInt(source.Int()), true
+	}
+}
+
+// isNumericKind reports whether values of the kind are integers or floating
+// point numbers.
+func isNumericKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
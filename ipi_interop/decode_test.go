@@ -0,0 +1,227 @@
+/* *********************************************************************
+ * This Original Work is copyright of 51 Degrees Mobile Experts Limited.
+ * Copyright 2019 51 Degrees Mobile Experts Limited, 5 Charlotte Close,
+ * Caversham, Reading, Berkshire, United Kingdom RG4 7BY.
+ *
+ * This Original Work is licensed under the European Union Public Licence (EUPL)
+ * v.1.2 and is subject to its terms as set out below.
+ *
+ * If a copy of the EUPL was not distributed with this file, You can obtain
+ * one at https://opensource.org/licenses/EUPL-1.2.
+ *
+ * The 'Compatible Licences' set out in the Appendix to the EUPL (as may be
+ * amended by the European Commission) shall be deemed incompatible for
+ * the purposes of the Work and the provisions of the compatibility
+ * clause in Article 5 of the EUPL shall not apply.
+ *
+ * If using the Work as, or as part of, a network application, by
+ * including the attribution notice(s) required under Article 5 of the EUPL
+ * in the end user terms of the application under an appropriate heading,
+ * such notice(s) shall fulfill the requirements of that article.
+ * ********************************************************************* */
+package ipi_interop
+
+import (
+	"errors"
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+type decodeTestTarget struct {
+	IpRangeStart      netip.Addr         `ipi:"IpRangeStart"`
+	IpRangeEnd        string             `ipi:"IpRangeEnd"`
+	RegisteredCountry string             `ipi:"RegisteredCountry"`
+	AccuracyRadius    int                `ipi:"AccuracyRadiusMin"`
+	Latitude          float64            `ipi:"Latitude"`
+	Areas             Geometry           `ipi:"Areas"`
+	RegisteredName    *string            `ipi:"RegisteredName"`
+	Longitude         *float32           `ipi:"Longitude"`
+	Mcc               []Weighted[string] `ipi:"Mcc"`
+	Raw               interface{}        `ipi:"IpRangeStart"`
+	Ignored           string             `ipi:"-"`
+	Untagged          string
+}
+
+func newDecodeTestValues() Values {
+	values := Values{}
+	values.AppendWithWeight("IpRangeStart", netip.MustParseAddr("10.0.0.0"), 1.0)
+	values.AppendWithWeight("IpRangeEnd", netip.MustParseAddr("10.0.0.255"), 1.0)
+	values.AppendWithWeight("RegisteredCountry", "GB", 1.0)
+	values.AppendWithWeight("AccuracyRadiusMin", 1000, 1.0)
+	values.AppendWithWeight("Latitude", float32(51.5), 1.0)
+	values.AppendWithWeight("Areas", Geometry{WKB: []byte{1}, WKT: "POINT(1 2)"}, 1.0)
+	values.AppendWithWeight("Longitude", float32(-0.125), 1.0)
+	values.AppendWithWeight("Mcc", "234", 0.75)
+	values.AppendWithWeight("Mcc", "235", 0.25)
+	values.InitProperty("RegisteredName")
+	return values
+}
+
+func TestValues_Decode(t *testing.T) {
+	target := decodeTestTarget{Ignored: "kept", Untagged: "kept"}
+	if err := newDecodeTestValues().Decode(&target); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	longitude := float32(-0.125)
+	want := decodeTestTarget{
+		IpRangeStart:      netip.MustParseAddr("10.0.0.0"),
+		IpRangeEnd:        "10.0.0.255",
+		RegisteredCountry: "GB",
+		AccuracyRadius:    1000,
+		Latitude:          51.5,
+		Areas:             Geometry{WKB: []byte{1}, WKT: "POINT(1 2)"},
+		RegisteredName:    nil,
+		Longitude:         &longitude,
+		Mcc: []Weighted[string]{
+			{Value: "234", Weight: 0.75},
+			{Value: "235", Weight: 0.25},
+		},
+		Raw:      netip.MustParseAddr("10.0.0.0"),
+		Ignored:  "kept",
+		Untagged: "kept",
+	}
+	if !reflect.DeepEqual(target, want) {
+		t.Errorf("Expected %+v, got %+v", want, target)
+	}
+}
+
+func TestValues_Decode_Errors(t *testing.T) {
+	type required struct {
+		Country string `ipi:"RegisteredCountry"`
+		Name    string `ipi:"RegisteredName"`
+	}
+	type wrongType struct {
+		Country int `ipi:"RegisteredCountry"`
+	}
+	type wrongWeighted struct {
+		Mcc []Weighted[int] `ipi:"Mcc"`
+	}
+	type fractional struct {
+		Latitude int `ipi:"Latitude"`
+	}
+	type overflow struct {
+		AccuracyRadius uint8 `ipi:"AccuracyRadiusMin"`
+	}
+
+	tests := []struct {
+		name    string
+		dst     interface{}
+		wantErr interface{}
+	}{
+		{name: "missing value", dst: &required{}, wantErr: &NoValueError{Reason: NoValueReasonNoResultForProperty}},
+		{name: "wrong type", dst: &wrongType{}, wantErr: new(*DecodeError)},
+		{name: "wrong weighted type", dst: &wrongWeighted{}, wantErr: new(*DecodeError)},
+		{name: "fractional float into int", dst: &fractional{}, wantErr: new(*DecodeError)},
+		{name: "int overflows field", dst: &overflow{}, wantErr: new(*DecodeError)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newDecodeTestValues().Decode(tt.dst)
+			switch want := tt.wantErr.(type) {
+			case error:
+				if !errors.Is(err, want) {
+					t.Errorf("Expected %v, got %v", want, err)
+				}
+			default:
+				if !errors.As(err, want) {
+					t.Errorf("Expected %T, got %v", want, err)
+				}
+			}
+		})
+	}
+}
+
+func TestValues_Decode_Numbers(t *testing.T) {
+	// Numbers convert between types when the value fits.
+	values := Values{}
+	values.AppendWithWeight("AccuracyRadiusMin", 1000, 1.0)
+	values.AppendWithWeight("Whole", 3.0, 1.0)
+	values.AppendWithWeight("Latitude", float32(51.5), 1.0)
+
+	var target struct {
+		AccuracyRadius uint16  `ipi:"AccuracyRadiusMin"`
+		Radius         float64 `ipi:"AccuracyRadiusMin"`
+		Whole          int8    `ipi:"Whole"`
+		Latitude       float64 `ipi:"Latitude"`
+	}
+	if err := values.Decode(&target); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if target.AccuracyRadius != 1000 || target.Radius != 1000 || target.Whole != 3 || target.Latitude != 51.5 {
+		t.Errorf("Unexpected values %+v", target)
+	}
+}
+
+// DecodeTestLocation is exported so that it can be embedded as a settable pointer.
+type DecodeTestLocation struct {
+	Country string `ipi:"RegisteredCountry"`
+}
+
+type DecodeTestNetwork struct {
+	Name *string `ipi:"RegisteredName"`
+}
+
+func TestValues_Decode_EmbeddedPointer(t *testing.T) {
+	// Nil embedded struct pointers are allocated to fill the fields promoted
+	// through them, except unexported ones, which cannot be set.
+	type hidden struct {
+		Latitude float64 `ipi:"Latitude"`
+	}
+	var target struct {
+		*DecodeTestLocation
+		*hidden
+		Network *DecodeTestNetwork
+	}
+	if err := newDecodeTestValues().Decode(&target); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if target.DecodeTestLocation == nil || target.Country != "GB" {
+		t.Errorf("Expected the embedded struct to be filled, got %+v", target.DecodeTestLocation)
+	}
+	if target.hidden != nil {
+		t.Errorf("Expected the unexported embedded struct to be left nil, got %+v", target.hidden)
+	}
+	if target.Network != nil {
+		t.Errorf("Expected a struct which is not embedded to be left alone, got %+v", target.Network)
+	}
+}
+
+func TestValues_Decode_PartialFill(t *testing.T) {
+	// Fields which can be decoded are filled even when another field fails.
+	var target struct {
+		Country string `ipi:"RegisteredCountry"`
+		Name    string `ipi:"RegisteredName"`
+	}
+	err := newDecodeTestValues().Decode(&target)
+	if err == nil {
+		t.Fatal("Expected an error for the missing name")
+	}
+	if target.Country != "GB" {
+		t.Errorf("Expected GB, got %s", target.Country)
+	}
+}
+
+func TestValues_Decode_InvalidTarget(t *testing.T) {
+	var target decodeTestTarget
+	var nilTarget *decodeTestTarget
+	tests := []struct {
+		name string
+		dst  interface{}
+	}{
+		{name: "nil", dst: nil},
+		{name: "not a pointer", dst: target},
+		{name: "nil pointer", dst: nilTarget},
+		{name: "pointer to non struct", dst: new(string)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := newDecodeTestValues().Decode(tt.dst); err == nil {
+				t.Error("Expected an error but got none")
+			}
+		})
+	}
+}
@@ -25,10 +25,28 @@ package ipi_interop
 //#include <string.h>
 //#include "ip-intelligence-cxx.h"
 import "C"
-import "unsafe"
+import (
+	"fmt"
+	"runtime"
+	"unsafe"
+)
 
 type EvidencePrefix C.fiftyoneDegreesEvidencePrefix
 
+// Evidence prefixes, matching the C enum fiftyoneDegreesEvidencePrefix
+const (
+	HttpHeaderString      EvidencePrefix = C.FIFTYONE_DEGREES_EVIDENCE_HTTP_HEADER_STRING
+	HttpHeaderIpAddresses EvidencePrefix = C.FIFTYONE_DEGREES_EVIDENCE_HTTP_HEADER_IP_ADDRESSES
+	HttpEvidenceServer    EvidencePrefix = C.FIFTYONE_DEGREES_EVIDENCE_SERVER
+	HttpEvidenceQuery     EvidencePrefix = C.FIFTYONE_DEGREES_EVIDENCE_QUERY
+	HttpEvidenceCookie    EvidencePrefix = C.FIFTYONE_DEGREES_EVIDENCE_COOKIE
+	HttpEvidenceIgnore    EvidencePrefix = C.FIFTYONE_DEGREES_EVIDENCE_IGNORE
+)
+
+// defaultEvidenceCapacity is the number of evidence the C array is created
+// with by NewEvidence. The C layer chains further arrays if more are added.
+const defaultEvidenceCapacity = 4
+
 // Header Key required by engine
 type EvidenceKey struct {
 	Prefix EvidencePrefix
@@ -47,10 +65,31 @@ type Evidence struct {
 	CPtr      *C.EvidenceKeyValuePairArray
 }
 
+// evidenceFinalizer check if C resource has been explicitly
+// freed by Free method. Panic if it was not.
+func evidenceFinalizer(evidence *Evidence) {
+	if evidence.CPtr != nil {
+		panic("ERROR: Evidence should be freed explicitly by its Free method.")
+	}
+}
+
+// NewEvidence creates a new Evidence object with room for a few evidence.
+// More evidence than that can still be added. This matches the C API
+// fiftyoneDegreesEvidenceCreate
 func NewEvidence() *Evidence {
-	return &Evidence{
+	return NewEvidenceWithCapacity(defaultEvidenceCapacity)
+}
+
+// NewEvidenceWithCapacity creates a new Evidence object with room for the
+// given number of evidence. More evidence than that can still be added. This
+// matches the C API fiftyoneDegreesEvidenceCreate
+func NewEvidenceWithCapacity(capacity uint32) *Evidence {
+	evidence := &Evidence{
 		cEvidence: make([]CEvidence, 0, 0),
+		CPtr:      C.EvidenceCreate(C.uint32_t(capacity)),
 	}
+	runtime.SetFinalizer(evidence, evidenceFinalizer)
+	return evidence
 }
 
 // Free frees the evidence resources allocated in the C layer. This matches the
@@ -73,9 +112,14 @@ func (evidence *Evidence) Free() {
 	}
 }
 
-// Count return number of evidence in Evidence object
+// Count return number of evidence in Evidence object, including any held in
+// arrays the C layer chained on when the initial capacity was exceeded.
 func (evidence *Evidence) Count() int {
-	return int(evidence.CPtr.count)
+	count := 0
+	for array := evidence.CPtr; array != nil; array = array.next {
+		count += int(array.count)
+	}
+	return count
 }
 
 // Add adds a new evidence to the object. This matches the C API
@@ -84,6 +128,10 @@ func (evidence *Evidence) Add(
 	prefix EvidencePrefix,
 	key string,
 	value string) error {
+	if evidence.CPtr == nil {
+		return &StatusError{Code: StatusNullPointer, Message: "evidence has been freed"}
+	}
+
 	cKey := C.CString(key)
 	cValue := C.CString(value)
 	// Add it to the tracked map
@@ -97,3 +145,29 @@ func (evidence *Evidence) Add(
 
 	return nil
 }
+
+// AddPrefixed adds a new evidence whose key includes its prefix, for example
+// "server.client-ip", "query.client-ip-51d" or "header.x-forwarded-for". The
+// prefix is resolved using the C API fiftyoneDegreesEvidenceMapPrefix.
+func (evidence *Evidence) AddPrefixed(key string, value string) error {
+	prefix, field, err := ParseEvidenceKey(key)
+	if err != nil {
+		return err
+	}
+	return evidence.Add(prefix, field, value)
+}
+
+// ParseEvidenceKey splits a prefixed evidence key such as "server.client-ip"
+// into its prefix and field name. Returns an error if the key does not start
+// with a known prefix.
+func ParseEvidenceKey(key string) (EvidencePrefix, string, error) {
+	cKey := C.CString(key)
+	defer C.free(unsafe.Pointer(cKey))
+
+	prefixMap := C.EvidenceMapPrefix(cKey)
+	if prefixMap == nil {
+		return HttpEvidenceIgnore, "", fmt.Errorf(ErrEvidenceKeyNoPrefix, key)
+	}
+
+	return EvidencePrefix(prefixMap.prefixEnum), key[int(prefixMap.prefixLength):], nil
+}
@@ -9,6 +9,15 @@ func TestNewEvidence(t *testing.T) {
 		if evidence == nil {
 			t.Fatal("NewEvidence returned nil")
 		}
+		defer evidence.Free()
+
+		if evidence.CPtr == nil {
+			t.Error("NewEvidence created evidence with nil CPtr")
+		}
+
+		if evidence.Count() != 0 {
+			t.Errorf("NewEvidence created evidence with count %d", evidence.Count())
+		}
 
 		if evidence.cEvidence == nil {
 			t.Error("NewEvidence created evidence with nil cEvidence")
@@ -25,3 +34,117 @@ func TestNewEvidence(t *testing.T) {
 		}
 	})
 }
+
+func TestEvidence_Add(t *testing.T) {
+	// Add more evidence than the initial capacity so the C layer has to chain
+	// another array.
+	evidence := NewEvidenceWithCapacity(1)
+	defer evidence.Free()
+
+	pairs := []struct {
+		prefix EvidencePrefix
+		key    string
+		value  string
+	}{
+		{HttpEvidenceServer, "client-ip", "185.28.167.77"},
+		{HttpEvidenceQuery, "client-ip-51d", "2001:4860:4860::8888"},
+		{HttpHeaderString, "x-forwarded-for", "8.8.8.8"},
+	}
+
+	for _, p := range pairs {
+		if err := evidence.Add(p.prefix, p.key, p.value); err != nil {
+			t.Fatalf("Add(%q) returned error: %v", p.key, err)
+		}
+	}
+
+	if evidence.Count() != len(pairs) {
+		t.Errorf("Count() = %d, want %d", evidence.Count(), len(pairs))
+	}
+}
+
+func TestEvidence_Add_AfterFree(t *testing.T) {
+	evidence := NewEvidence()
+	evidence.Free()
+
+	if err := evidence.Add(HttpEvidenceServer, "client-ip", "185.28.167.77"); err == nil {
+		t.Error("expected error adding to freed evidence")
+	}
+}
+
+func TestParseEvidenceKey(t *testing.T) {
+	tests := []struct {
+		name       string
+		key        string
+		wantPrefix EvidencePrefix
+		wantField  string
+		wantErr    bool
+	}{
+		{
+			name:       "server prefix",
+			key:        "server.client-ip",
+			wantPrefix: HttpEvidenceServer,
+			wantField:  "client-ip",
+		},
+		{
+			name:       "query prefix",
+			key:        "query.client-ip-51d",
+			wantPrefix: HttpEvidenceQuery,
+			wantField:  "client-ip-51d",
+		},
+		{
+			name:       "header prefix",
+			key:        "header.x-forwarded-for",
+			wantPrefix: HttpHeaderString,
+			wantField:  "x-forwarded-for",
+		},
+		{
+			name:       "cookie prefix",
+			key:        "cookie.session",
+			wantPrefix: HttpEvidenceCookie,
+			wantField:  "session",
+		},
+		{
+			name:    "unknown prefix",
+			key:     "unknown.client-ip",
+			wantErr: true,
+		},
+		{
+			name:    "prefix only",
+			key:     "server.",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefix, field, err := ParseEvidenceKey(tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseEvidenceKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if prefix != tt.wantPrefix {
+				t.Errorf("ParseEvidenceKey() prefix = %v, want %v", prefix, tt.wantPrefix)
+			}
+			if field != tt.wantField {
+				t.Errorf("ParseEvidenceKey() field = %q, want %q", field, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestEvidence_AddPrefixed(t *testing.T) {
+	evidence := NewEvidence()
+	defer evidence.Free()
+
+	if err := evidence.AddPrefixed("server.client-ip", "185.28.167.77"); err != nil {
+		t.Fatalf("AddPrefixed returned error: %v", err)
+	}
+	if err := evidence.AddPrefixed("client-ip", "185.28.167.77"); err == nil {
+		t.Error("expected error for key without prefix")
+	}
+	if evidence.Count() != 1 {
+		t.Errorf("Count() = %d, want 1", evidence.Count())
+	}
+}
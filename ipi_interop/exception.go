@@ -73,3 +73,21 @@ func (e *Exception) IsOkay() bool {
 	return (e.CPtr == nil ||
 		e.CPtr.status == C.FIFTYONE_DEGREES_STATUS_NOT_SET)
 }
+
+// Err returns the thrown exception as a *StatusError carrying the status code
+// and the C source location, or nil if no exception has been thrown.
+func (e *Exception) Err() error {
+	if e.IsOkay() {
+		return nil
+	}
+
+	err := newStatusError(e.CPtr.status, nil)
+	if e.CPtr.file != nil {
+		err.File = C.GoString(e.CPtr.file)
+	}
+	if e.CPtr._func != nil {
+		err.Function = C.GoString(e.CPtr._func)
+	}
+	err.Line = int(e.CPtr.line)
+	return err
+}
@@ -0,0 +1,427 @@
+/* *********************************************************************
+ * This Original Work is copyright of 51 Degrees Mobile Experts Limited.
+ * Copyright 2019 51 Degrees Mobile Experts Limited, 5 Charlotte Close,
+ * Caversham, Reading, Berkshire, United Kingdom RG4 7BY.
+ *
+ * This Original Work is licensed under the European Union Public Licence (EUPL)
+ * v.1.2 and is subject to its terms as set out below.
+ *
+ * If a copy of the EUPL was not distributed with this file, You can obtain
+ * one at https://opensource.org/licenses/EUPL-1.2.
+ *
+ * The 'Compatible Licences' set out in the Appendix to the EUPL (as may be
+ * amended by the European Commission) shall be deemed incompatible for
+ * the purposes of the Work and the provisions of the compatibility
+ * clause in Article 5 of the EUPL shall not apply.
+ *
+ * If using the Work as, or as part of, a network application, by
+ * including the attribution notice(s) required under Article 5 of the EUPL
+ * in the end user terms of the application under an appropriate heading,
+ * such notice(s) shall fulfill the requirements of that article.
+ * ********************************************************************* */
+
+package ipi_interop
+
+/*
+#include <stdlib.h>
+#include <string.h>
+#include "ip-intelligence-cxx.h"
+
+// Defined in profile_values.go.
+typedef struct profile_value_t {
+	char *property;
+	char *value;
+	byte valueType;
+} profileValue;
+profileValue* profileValues(
+	fiftyoneDegreesDataSetIpi *dataSet,
+	const fiftyoneDegreesProfile *profile,
+	uint32_t *count,
+	fiftyoneDegreesException *exception);
+
+// The full raw weighting of a profile, as used by the C layer.
+#define FULL_RAW_WEIGHTING 0xFFFF
+
+// profileWeight mirrors an entry of the profile groups collection, the
+// offsetPercentage structure of the C layer.
+#pragma pack(push, 1)
+typedef struct profile_weight_t {
+	uint32_t offset;
+	uint16_t rawWeighting;
+} profileWeight;
+#pragma pack(pop)
+
+// Defined in profile_values.go.
+extern const fiftyoneDegreesCollectionKeyType integerKeyType;
+extern const fiftyoneDegreesCollectionKeyType profileKeyType;
+
+// The key type the C layer uses for the profile groups collection, which it
+// does not export.
+static const fiftyoneDegreesCollectionKeyType profileWeightKeyType = {
+	FIFTYONE_DEGREES_COLLECTION_ENTRY_TYPE_OFFSET_PERCENTAGE,
+	sizeof(profileWeight),
+	NULL,
+};
+
+// graphTraced returns true if the C layer is built to record the graph nodes
+// visited, see cgoflags_trace.go.
+static bool graphTraced(void) {
+#ifdef FIFTYONE_DEGREES_IPI_GRAPH_TRACE
+	return true;
+#else
+	return false;
+#endif
+}
+
+// tracedProfile is a profile chosen by a graph with its weight and values.
+// The values must be freed.
+typedef struct traced_profile_t {
+	uint32_t offset;
+	uint16_t rawWeighting;
+	profileValue *values;
+	uint32_t valueCount;
+} tracedProfile;
+
+// parseIpAddress parses the IP address string in the same way as lookups.
+static bool parseIpAddress(const char *ipAddress, fiftyoneDegreesIpAddress *address) {
+	memset(address, 0, sizeof(fiftyoneDegreesIpAddress));
+	return fiftyoneDegreesIpAddressParse(
+		ipAddress, ipAddress + strlen(ipAddress), address);
+}
+
+// evaluateTrace evaluates the graph of the component for the address, setting
+// trace to the trace written by the graph, which the caller must free. The
+// trace only has the nodes visited when the C layer is built with
+// FIFTYONE_DEGREES_IPI_GRAPH_TRACE.
+static fiftyoneDegreesIpiCgResult evaluateTrace(
+	fiftyoneDegreesDataSetIpi *dataSet,
+	byte componentId,
+	fiftyoneDegreesIpAddress *address,
+	char **trace,
+	fiftyoneDegreesException *exception) {
+	fiftyoneDegreesIpiCgResult result;
+	size_t length = 4096;
+
+	// The buffer is zeroed and the builder never writes its last byte, so
+	// the trace is always terminated. A trace filling the buffer may have been
+	// cut short, so evaluate again with a larger buffer.
+	for (;;) {
+		*trace = (char*)calloc(length, 1);
+		if (*trace == NULL) {
+			return fiftyoneDegreesIpiGraphEvaluate(
+				dataSet->graphsArray, componentId, *address, exception);
+		}
+		result = fiftyoneDegreesIpiGraphEvaluateTrace(
+			dataSet->graphsArray,
+			componentId,
+			*address,
+			*trace,
+			(int)length,
+			exception);
+		if (strlen(*trace) < length - 1 || length >= (1 << 24)) {
+			return result;
+		}
+		free(*trace);
+		length *= 4;
+	}
+}
+
+// addTracedProfile adds the profile at the offset in the profiles collection
+// to the array, with its values if withValues is set, returning false if it
+// cannot be read.
+static bool addTracedProfile(
+	fiftyoneDegreesDataSetIpi *dataSet,
+	uint32_t offset,
+	uint16_t rawWeighting,
+	bool withValues,
+	tracedProfile *profiles,
+	uint32_t *count,
+	fiftyoneDegreesException *exception) {
+	fiftyoneDegreesCollectionItem item;
+	fiftyoneDegreesCollectionKey key = { offset, &profileKeyType };
+	const fiftyoneDegreesProfile *profile;
+	tracedProfile *traced = &profiles[*count];
+
+	if (withValues == false) {
+		traced->offset = offset;
+		traced->rawWeighting = rawWeighting;
+		(*count)++;
+		return true;
+	}
+	fiftyoneDegreesDataReset(&item.data);
+	profile = (const fiftyoneDegreesProfile*)dataSet->profiles->get(
+		dataSet->profiles, &key, &item, exception);
+	if (profile == NULL || FIFTYONE_DEGREES_EXCEPTION_FAILED) {
+		return false;
+	}
+	traced->offset = offset;
+	traced->rawWeighting = rawWeighting;
+	traced->values = profileValues(
+		dataSet, profile, &traced->valueCount, exception);
+	(*count)++;
+	FIFTYONE_DEGREES_COLLECTION_RELEASE(dataSet->profiles, &item);
+	return true;
+}
+
+// getTracedProfiles returns the profiles the graph result refers to, either
+// the single profile or every profile of the profile group, setting count to
+// the number returned. The values of each profile are only read if withValues
+// is set. The array and the values of each profile must be freed.
+static tracedProfile* getTracedProfiles(
+	fiftyoneDegreesDataSetIpi *dataSet,
+	fiftyoneDegreesIpiCgResult result,
+	bool withValues,
+	uint32_t *count,
+	fiftyoneDegreesException *exception) {
+	fiftyoneDegreesCollectionItem item;
+	fiftyoneDegreesCollectionKey key;
+	const profileWeight *weight;
+	tracedProfile *profiles, *grown;
+	uint32_t capacity = 4, total = 0, offset;
+
+	*count = 0;
+	if (result.rawOffset == UINT32_MAX) {
+		return NULL;
+	}
+	profiles = (tracedProfile*)calloc(capacity, sizeof(tracedProfile));
+	if (profiles == NULL) {
+		return NULL;
+	}
+
+	if (result.isGroupOffset == false) {
+		// The offset is the index of the profile's offset.
+		key.indexOrOffset.offset = result.offset;
+		key.keyType = &integerKeyType;
+		fiftyoneDegreesDataReset(&item.data);
+		if (dataSet->profileOffsets->get(
+			dataSet->profileOffsets, &key, &item, exception) != NULL &&
+			FIFTYONE_DEGREES_EXCEPTION_OKAY) {
+			offset = *(const uint32_t*)item.data.ptr;
+			FIFTYONE_DEGREES_COLLECTION_RELEASE(dataSet->profileOffsets, &item);
+			addTracedProfile(
+				dataSet,
+				offset,
+				FULL_RAW_WEIGHTING,
+				withValues,
+				profiles,
+				count,
+				exception);
+		}
+		return profiles;
+	}
+
+	// The profiles of a group follow each other until their weights add up to
+	// the full weight, as the C layer reads them for lookups.
+	for (offset = result.offset;
+		total < FULL_RAW_WEIGHTING && FIFTYONE_DEGREES_EXCEPTION_OKAY;
+		offset++) {
+		key.indexOrOffset.offset = offset;
+		key.keyType = &profileWeightKeyType;
+		fiftyoneDegreesDataReset(&item.data);
+		weight = (const profileWeight*)dataSet->profileGroups->get(
+			dataSet->profileGroups, &key, &item, exception);
+		if (weight == NULL || FIFTYONE_DEGREES_EXCEPTION_FAILED) {
+			break;
+		}
+		total += weight->rawWeighting;
+		if (*count == capacity) {
+			grown = (tracedProfile*)realloc(
+				profiles, capacity * 2 * sizeof(tracedProfile));
+			if (grown == NULL) {
+				FIFTYONE_DEGREES_COLLECTION_RELEASE(
+					dataSet->profileGroups, &item);
+				break;
+			}
+			profiles = grown;
+			memset(profiles + capacity, 0, capacity * sizeof(tracedProfile));
+			capacity *= 2;
+		}
+		addTracedProfile(
+			dataSet,
+			weight->offset,
+			weight->rawWeighting,
+			withValues,
+			profiles,
+			count,
+			exception);
+		FIFTYONE_DEGREES_COLLECTION_RELEASE(dataSet->profileGroups, &item);
+	}
+	return profiles;
+}
+*/
+import "C"
+import (
+	"fmt"
+	"strings"
+	"unsafe"
+)
+
+// Explanation describes how the graphs of the data set evaluated an IP
+// address, component by component.
+type Explanation struct {
+	IpAddress  string           // IP address which was evaluated
+	Traced     bool             // Whether the graph nodes visited were recorded, see Explain
+	Components []ComponentTrace // Evaluation of the graph of each component
+}
+
+// ComponentTrace describes how the graph of a component evaluated an IP
+// address. The graph does not hold the bounds of the IP range it matched, it
+// finds the range by comparing the address with the spans of its nodes, which
+// are listed in Nodes.
+type ComponentTrace struct {
+	ComponentID  byte            // Unique id of the component in the data file
+	Component    string          // Name of the component, e.g. Location
+	Matched      bool            // Whether the graph matched a range with a profile or profile group
+	RawOffset    uint32          // Offset returned by the graph, before it is mapped
+	Offset       uint32          // Index of the profile's offset, or offset of the profile group
+	ProfileGroup bool            // Whether Offset refers to a profile group rather than a single profile
+	Profiles     []TracedProfile // Profiles the matched range refers to, with their weights
+	Nodes        []string        // Graph nodes visited, as written by the C layer's trace
+}
+
+// TracedProfile is a profile which a graph chose for an IP address.
+type TracedProfile struct {
+	Offset       uint32 // Offset of the profile in the profiles collection
+	RawWeighting uint16 // Weight of the profile in the matched range, out of 65535
+	Values       Values // Values of the profile, each weighted with the profile's weight
+}
+
+// Explain evaluates the graph of every component of the manager's current
+// data set for the IP address, returning the profile or profile group each
+// graph chose and the values and raw weightings behind them. The nodes visited
+// are only recorded when the package is built with the ipi_graph_trace build
+// tag, which compiles the C layer with graph tracing: the C library documents
+// its trace as a diagnostic aid which is not intended for production use.
+// Without it Explanation.Traced is false and every ComponentTrace has no
+// Nodes. The start and end of the IP range matched are not returned in either
+// build, as the graphs do not hold ranges; with tracing the nodes visited show
+// the spans the address was compared with.
+func Explain(manager *ResourceManager, ipAddress string) (*Explanation, error) {
+	cIpAddress := C.CString(ipAddress)
+	defer C.free(unsafe.Pointer(cIpAddress))
+
+	var address C.fiftyoneDegreesIpAddress
+	if !C.parseIpAddress(cIpAddress, &address) {
+		return nil, &StatusError{
+			Code:    StatusIncorrectIpAddressFormat,
+			Message: fmt.Sprintf("invalid IP address: %q", ipAddress),
+		}
+	}
+
+	cDataSet := (*C.DataSetIpi)(unsafe.Pointer(C.DataSetGet(manager.CPtr)))
+	defer C.DataSetRelease((*C.DataSetBase)(unsafe.Pointer(cDataSet)))
+
+	exception := NewException()
+	defer exception.Free()
+
+	explanation := &Explanation{IpAddress: ipAddress, Traced: bool(C.graphTraced())}
+	for _, info := range components(cDataSet) {
+		component := ComponentTrace{
+			ComponentID: info.ID,
+			Component:   info.Name,
+		}
+
+		exception.Clear()
+		var trace *C.char
+		result := C.evaluateTrace(cDataSet, C.byte(info.ID), &address, &trace, exception.CPtr)
+		component.Nodes = traceNodes(takeString(trace))
+		if err := exception.Err(); err != nil {
+			return nil, err
+		}
+		component.RawOffset = uint32(result.rawOffset)
+		component.Offset = uint32(result.offset)
+		component.ProfileGroup = bool(result.isGroupOffset)
+		component.Matched = result.rawOffset != C.UINT32_MAX
+
+		var count C.uint32_t
+		profiles := C.getTracedProfiles(cDataSet, result, true, &count, exception.CPtr)
+		if profiles != nil {
+			for _, profile := range unsafe.Slice(profiles, count) {
+				weight := float64(profile.rawWeighting) / uint16Max
+				component.Profiles = append(component.Profiles, TracedProfile{
+					Offset:       uint32(profile.offset),
+					RawWeighting: uint16(profile.rawWeighting),
+					Values:       takeProfileValues(profile.values, profile.valueCount, weight),
+				})
+			}
+			C.free(unsafe.Pointer(profiles))
+		}
+		if err := exception.Err(); err != nil {
+			return nil, err
+		}
+
+		explanation.Components = append(explanation.Components, component)
+	}
+	return explanation, nil
+}
+
+// traceNodes splits the trace written by the C layer into its lines, leaving
+// out the IP address it starts with, which the first node follows on the same
+// line.
+func traceNodes(trace string) []string {
+	var nodes []string
+	for _, line := range strings.Split(trace, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "IP:") {
+			_, line, _ = strings.Cut(line, "\t")
+		}
+		if line == "" {
+			continue
+		}
+		nodes = append(nodes, line)
+	}
+	return nodes
+}
+
+// MatchedProfile is a profile which the graph of a component chose for the IP
+// address of a lookup.
+type MatchedProfile struct {
+	ComponentID  byte   // Unique id of the component the profile belongs to
+	Offset       uint32 // Offset of the profile in the profiles collection, see GetProfileValues
+	RawWeighting uint16 // Weight of the profile in the matched range, out of 65535
+}
+
+// MatchedProfiles returns the profiles the last lookup made with the results
+// matched, component by component, with the offsets GetProfileValues takes.
+// The results do not keep the profile of every component, so the graph of
+// each component is evaluated again for the IP address of the lookup. No
+// profiles are returned if the results hold no values.
+func (r *ResultsIpi) MatchedProfiles() ([]MatchedProfile, error) {
+	if r.CPtr == nil {
+		return nil, &StatusError{Code: StatusNullPointer, Message: "results have been freed"}
+	}
+	if !r.HasValues() {
+		return nil, nil
+	}
+	cDataSet := (*C.DataSetIpi)(r.CPtr.b.dataSet)
+	address := r.CPtr.items.targetIpAddress
+
+	exception := NewException()
+	defer exception.Free()
+
+	var matched []MatchedProfile
+	for _, info := range components(cDataSet) {
+		exception.Clear()
+		result := C.fiftyoneDegreesIpiGraphEvaluate(cDataSet.graphsArray, C.byte(info.ID), address, exception.CPtr)
+		if err := exception.Err(); err != nil {
+			return nil, err
+		}
+
+		var count C.uint32_t
+		profiles := C.getTracedProfiles(cDataSet, result, false, &count, exception.CPtr)
+		if profiles != nil {
+			for _, profile := range unsafe.Slice(profiles, count) {
+				matched = append(matched, MatchedProfile{
+					ComponentID:  info.ID,
+					Offset:       uint32(profile.offset),
+					RawWeighting: uint16(profile.rawWeighting),
+				})
+			}
+			C.free(unsafe.Pointer(profiles))
+		}
+		if err := exception.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return matched, nil
+}
@@ -0,0 +1,136 @@
+/* *********************************************************************
+ * This Original Work is copyright of 51 Degrees Mobile Experts Limited.
+ * Copyright 2019 51 Degrees Mobile Experts Limited, 5 Charlotte Close,
+ * Caversham, Reading, Berkshire, United Kingdom RG4 7BY.
+ *
+ * This Original Work is licensed under the European Union Public Licence (EUPL)
+ * v.1.2 and is subject to its terms as set out below.
+ *
+ * If a copy of the EUPL was not distributed with this file, You can obtain
+ * one at https://opensource.org/licenses/EUPL-1.2.
+ *
+ * The 'Compatible Licences' set out in the Appendix to the EUPL (as may be
+ * amended by the European Commission) shall be deemed incompatible for
+ * the purposes of the Work and the provisions of the compatibility
+ * clause in Article 5 of the EUPL shall not apply.
+ *
+ * If using the Work as, or as part of, a network application, by
+ * including the attribution notice(s) required under Article 5 of the EUPL
+ * in the end user terms of the application under an appropriate heading,
+ * such notice(s) shall fulfill the requirements of that article.
+ * ********************************************************************* */
+
+package ipi_interop
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestTraceNodes(t *testing.T) {
+	tests := []struct {
+		name  string
+		trace string
+		want  []string
+	}{
+		{
+			name:  "empty",
+			trace: "",
+			want:  nil,
+		},
+		{
+			name:  "address only",
+			trace: "\r\nIP:1.2.3.4",
+			want:  nil,
+		},
+		{
+			name:  "nodes",
+			trace: "\r\nIP:1.2.3.4\tgetIsProfileIndex=false\r\n[0]=INBETWEEN IP:0000 LV:0000 HV:1111 CLI:0 SI:0 CI:0\r\n\r\nresult=12\r\nraw result=12\r\nis group=0\r\n",
+			want: []string{
+				"getIsProfileIndex=false",
+				"[0]=INBETWEEN IP:0000 LV:0000 HV:1111 CLI:0 SI:0 CI:0",
+				"result=12",
+				"raw result=12",
+				"is group=0",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := traceNodes(tt.trace); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestExplain_InvalidIpAddress(t *testing.T) {
+	_, err := Explain(NewResourceManager(), "not an ip")
+	if !errors.Is(err, ErrInvalidIpAddress) {
+		t.Errorf("Expected %v, got %v", ErrInvalidIpAddress, err)
+	}
+}
+
+func TestResultsIpi_MatchedProfiles_Freed(t *testing.T) {
+	results := &ResultsIpi{}
+	if _, err := results.MatchedProfiles(); !errors.Is(err, ErrNullPointer) {
+		t.Errorf("Expected %v, got %v", ErrNullPointer, err)
+	}
+}
+
+func TestResultsIpi_MatchedProfiles(t *testing.T) {
+	manager := newTestManager(t)
+	results := NewResultsIpi(manager)
+	defer results.Free()
+
+	if err := results.ResultsIpiFromIpAddress("8.8.8.8"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	matched, err := results.MatchedProfiles()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// The lookup matches the same profiles as Explain reports.
+	explanation, err := Explain(manager, "8.8.8.8")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var want []MatchedProfile
+	for _, component := range explanation.Components {
+		for _, profile := range component.Profiles {
+			want = append(want, MatchedProfile{
+				ComponentID:  component.ComponentID,
+				Offset:       profile.Offset,
+				RawWeighting: profile.RawWeighting,
+			})
+		}
+	}
+	if !reflect.DeepEqual(matched, want) {
+		t.Errorf("Expected %v, got %v", want, matched)
+	}
+	for _, profile := range matched {
+		if _, err := GetProfileValues(manager, profile.Offset); err != nil {
+			t.Errorf("Expected the values of the profile at %d, got %v", profile.Offset, err)
+		}
+	}
+}
+
+func TestExplain_Traced(t *testing.T) {
+	manager := newTestManager(t)
+
+	explanation, err := Explain(manager, "8.8.8.8")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	nodes := 0
+	for _, component := range explanation.Components {
+		nodes += len(component.Nodes)
+	}
+	// Without the ipi_graph_trace build tag no nodes are recorded, which is reported.
+	if explanation.Traced != (nodes > 0) {
+		t.Errorf("Expected Traced to be %v with %d nodes, got %v", nodes > 0, nodes, explanation.Traced)
+	}
+}
@@ -0,0 +1,214 @@
+/* *********************************************************************
+ * This Original Work is copyright of 51 Degrees Mobile Experts Limited.
+ * Copyright 2019 51 Degrees Mobile Experts Limited, 5 Charlotte Close,
+ * Caversham, Reading, Berkshire, United Kingdom RG4 7BY.
+ *
+ * This Original Work is licensed under the European Union Public Licence (EUPL)
+ * v.1.2 and is subject to its terms as set out below.
+ *
+ * If a copy of the EUPL was not distributed with this file, You can obtain
+ * one at https://opensource.org/licenses/EUPL-1.2.
+ *
+ * The 'Compatible Licences' set out in the Appendix to the EUPL (as may be
+ * amended by the European Commission) shall be deemed incompatible for
+ * the purposes of the Work and the provisions of the compatibility
+ * clause in Article 5 of the EUPL shall not apply.
+ *
+ * If using the Work as, or as part of, a network application, by
+ * including the attribution notice(s) required under Article 5 of the EUPL
+ * in the end user terms of the application under an appropriate heading,
+ * such notice(s) shall fulfill the requirements of that article.
+ * ********************************************************************* */
+
+package ipi_interop
+
+/*
+#include "ip-intelligence-cxx.h"
+
+// collectionSize returns the bytes a collection with the header keeps in
+// memory with the config: all of it if it is loaded, or as many of its
+// average sized items as the cache holds.
+static uint64_t collectionSize(
+	const fiftyoneDegreesCollectionHeader *header,
+	const fiftyoneDegreesCollectionConfig *config) {
+	uint64_t items;
+	if (config->loaded) {
+		return header->length;
+	}
+	if (config->capacity == 0 || header->count == 0) {
+		return 0;
+	}
+	items = config->capacity < header->count ? config->capacity : header->count;
+	return items * (((uint64_t)header->length + header->count - 1) / header->count);
+}
+
+// collectionFits returns true if the collection with the header lies within
+// a data file of fileSize bytes.
+static bool collectionFits(
+	const fiftyoneDegreesCollectionHeader *header,
+	uint64_t fileSize) {
+	return (uint64_t)header->startPosition + header->length <= fileSize;
+}
+
+// headerSize returns the bytes the collections in the data set header keep in
+// memory with the config, or false if the header does not describe a data
+// file of fileSize bytes. The graphs are sized by graphsSize.
+static bool headerSize(
+	const void *data,
+	const fiftyoneDegreesConfigIpi *config,
+	uint64_t fileSize,
+	uint64_t *size) {
+	// The header is packed, so its fields are not aligned.
+	const fiftyoneDegreesDataSetIpiHeader *header = data;
+	const fiftyoneDegreesCollectionHeader *collections[] = {
+		&header->strings, &header->components, &header->maps,
+		&header->properties, &header->values, &header->profiles,
+		&header->graphs, &header->profileGroups, &header->propertyTypes,
+		&header->profileOffsets,
+	};
+	const fiftyoneDegreesCollectionConfig *configs[] = {
+		&config->strings, &config->components, &config->maps,
+		&config->properties, &config->values, &config->profiles,
+		&config->graphs, &config->profileGroups, &config->propertyTypes,
+		&config->profileOffsets,
+	};
+	*size = 0;
+	for (int i = 0; i < (int)(sizeof(collections) / sizeof(collections[0])); i++) {
+		if (!collectionFits(collections[i], fileSize)) {
+			return false;
+		}
+		*size += collectionSize(collections[i], configs[i]);
+	}
+	return true;
+}
+
+// graphsPosition sets where the graph information records start in the data
+// file, and their number, or returns false if they do not fit the length of
+// the graphs collection.
+static bool graphsPosition(
+	const void *data,
+	fiftyoneDegreesFileOffsetUnsigned *position,
+	uint32_t *count) {
+	const fiftyoneDegreesDataSetIpiHeader *header = data;
+	*position = header->graphs.startPosition;
+	*count = header->graphs.count;
+	return (uint64_t)*count * sizeof(fiftyoneDegreesIpiCgInfo) <= header->graphs.length;
+}
+
+// graphsSize returns the bytes the collections of the graphs described by the
+// count information records keep in memory with the config, or false if they
+// do not lie within a data file of fileSize bytes.
+static bool graphsSize(
+	const void *data,
+	uint32_t count,
+	const fiftyoneDegreesConfigIpi *config,
+	uint64_t fileSize,
+	uint64_t *size) {
+	const fiftyoneDegreesIpiCgInfo *infos = data;
+	*size = 0;
+	for (uint32_t i = 0; i < count; i++) {
+		const fiftyoneDegreesCollectionHeader *collections[] = {
+			&infos[i].spanBytes, &infos[i].spans, &infos[i].clusters,
+			&infos[i].nodes.collection,
+		};
+		for (int j = 0; j < 4; j++) {
+			if (!collectionFits(collections[j], fileSize)) {
+				return false;
+			}
+			*size += collectionSize(collections[j], &config->graph);
+		}
+	}
+	return true;
+}
+*/
+import "C"
+import (
+	"bytes"
+	"io"
+	"os"
+	"unsafe"
+)
+
+// EstimateManagerSize returns the number of bytes of memory a resource
+// manager initialised from the data file at filePath, with the given config,
+// is estimated to need.
+//
+// The estimate is coarse. It is read from the header of the data file, which
+// gives the size of each of its collections, and the config, which says which
+// collections are loaded into memory and how many items the caches of the
+// others hold; with the InMemory profile it is the size of the whole data
+// file. The properties a manager is initialised with do not change it, as
+// every collection is kept whichever properties are required. Cached items
+// are counted at the average item size of their collection, and the
+// bookkeeping of the caches and the indexes built when the data set is
+// initialised are left out. The data file is not loaded to measure it, so
+// estimating is quick and does not hold up lookups.
+func EstimateManagerSize(config ConfigIpi, filePath string) (uint64, error) {
+	file, err := os.Open(filePath)
+	if os.IsNotExist(err) {
+		return 0, &StatusError{Code: StatusFileNotFound, Message: err.Error()}
+	} else if err != nil {
+		return 0, &StatusError{Code: StatusFileFailure, Message: err.Error()}
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return 0, &StatusError{Code: StatusFileFailure, Message: err.Error()}
+	}
+	return estimateSize(config, file, uint64(stat.Size()))
+}
+
+// EstimateManagerSizeFromMemory is the same as EstimateManagerSize for a data
+// file which has already been read into memory, see InitManagerFromMemory.
+// InitManagerFromMemory copies data and reads the data set from the copy
+// whatever the config, so the estimate is the size of data. The header is
+// still checked, so that data which is not a data file is not estimated.
+func EstimateManagerSizeFromMemory(data []byte) (uint64, error) {
+	if len(data) == 0 {
+		return 0, &StatusError{Code: StatusCorruptData, Message: ErrDataEmpty}
+	}
+	if _, err := estimateSize(*NewConfigIpi(InMemory), bytes.NewReader(data), uint64(len(data))); err != nil {
+		return 0, err
+	}
+	return uint64(len(data)), nil
+}
+
+// estimateSize returns the estimated size of a data set read from the data
+// file in r, of size bytes, see EstimateManagerSize.
+func estimateSize(config ConfigIpi, r io.ReaderAt, size uint64) (uint64, error) {
+	header := make([]byte, C.sizeof_fiftyoneDegreesDataSetIpiHeader)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return 0, errCorruptHeader
+	}
+
+	var collections C.uint64_t
+	if !C.headerSize(unsafe.Pointer(&header[0]), config.CPtr, C.uint64_t(size), &collections) {
+		return 0, errCorruptHeader
+	}
+
+	var position C.fiftyoneDegreesFileOffsetUnsigned
+	var count C.uint32_t
+	if !C.graphsPosition(unsafe.Pointer(&header[0]), &position, &count) {
+		return 0, errCorruptHeader
+	}
+	var graphs C.uint64_t
+	if count > 0 {
+		infos := make([]byte, int(count)*C.sizeof_fiftyoneDegreesIpiCgInfo)
+		if _, err := r.ReadAt(infos, int64(position)); err != nil {
+			return 0, errCorruptHeader
+		}
+		if !C.graphsSize(unsafe.Pointer(&infos[0]), count, config.CPtr, C.uint64_t(size), &graphs) {
+			return 0, errCorruptHeader
+		}
+	}
+
+	if config.CPtr.b.allInMemory {
+		return size, nil
+	}
+	return uint64(collections) + uint64(graphs), nil
+}
+
+// errCorruptHeader is returned when the header of a data file being estimated
+// does not describe the data file.
+var errCorruptHeader = &StatusError{Code: StatusCorruptData, Message: "the data file header does not describe the data file"}
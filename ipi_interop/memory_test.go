@@ -0,0 +1,65 @@
+/* *********************************************************************
+ * This Original Work is copyright of 51 Degrees Mobile Experts Limited.
+ * Copyright 2019 51 Degrees Mobile Experts Limited, 5 Charlotte Close,
+ * Caversham, Reading, Berkshire, United Kingdom RG4 7BY.
+ *
+ * This Original Work is licensed under the European Union Public Licence (EUPL)
+ * v.1.2 and is subject to its terms as set out below.
+ *
+ * If a copy of the EUPL was not distributed with this file, You can obtain
+ * one at https://opensource.org/licenses/EUPL-1.2.
+ *
+ * The 'Compatible Licences' set out in the Appendix to the EUPL (as may be
+ * amended by the European Commission) shall be deemed incompatible for
+ * the purposes of the Work and the provisions of the compatibility
+ * clause in Article 5 of the EUPL shall not apply.
+ *
+ * If using the Work as, or as part of, a network application, by
+ * including the attribution notice(s) required under Article 5 of the EUPL
+ * in the end user terms of the application under an appropriate heading,
+ * such notice(s) shall fulfill the requirements of that article.
+ * ********************************************************************* */
+
+package ipi_interop
+
+import (
+	"testing"
+)
+
+func TestEstimateManagerSize(t *testing.T) {
+	tests := []struct {
+		name     string
+		filePath string
+	}{
+		{name: "missing file", filePath: "/path/to/51Degrees-LiteIpiV41.ipi"},
+		{name: "not a data file", filePath: "memory.go"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			size, err := EstimateManagerSize(*NewConfigIpi(InMemory), tt.filePath)
+			if err == nil {
+				t.Errorf("Expected error, got size %d", size)
+			}
+		})
+	}
+}
+
+func TestEstimateManagerSizeFromMemory(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "empty data", data: nil},
+		{name: "corrupt data", data: []byte("not a data file")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			size, err := EstimateManagerSizeFromMemory(tt.data)
+			if err == nil {
+				t.Errorf("Expected error, got size %d", size)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+/* *********************************************************************
+ * This Original Work is copyright of 51 Degrees Mobile Experts Limited.
+ * Copyright 2019 51 Degrees Mobile Experts Limited, 5 Charlotte Close,
+ * Caversham, Reading, Berkshire, United Kingdom RG4 7BY.
+ *
+ * This Original Work is licensed under the European Union Public Licence (EUPL)
+ * v.1.2 and is subject to its terms as set out below.
+ *
+ * If a copy of the EUPL was not distributed with this file, You can obtain
+ * one at https://opensource.org/licenses/EUPL-1.2.
+ *
+ * The 'Compatible Licences' set out in the Appendix to the EUPL (as may be
+ * amended by the European Commission) shall be deemed incompatible for
+ * the purposes of the Work and the provisions of the compatibility
+ * clause in Article 5 of the EUPL shall not apply.
+ *
+ * If using the Work as, or as part of, a network application, by
+ * including the attribution notice(s) required under Article 5 of the EUPL
+ * in the end user terms of the application under an appropriate heading,
+ * such notice(s) shall fulfill the requirements of that article.
+ * ********************************************************************* */
+
+package ipi_interop
+
+//#include <string.h>
+//#include "ip-intelligence-cxx.h"
+import "C"
+import "fmt"
+
+// NoValueReason mirrors the C enum fiftyoneDegreesResultsNoValueReason and
+// explains why results hold no values, or no values for a property.
+type NoValueReason int
+
+// https://github.com/51Degrees/common-cxx/blob/version/4.5/results.h
+const (
+	NoValueReasonDifference          NoValueReason = C.FIFTYONE_DEGREES_RESULTS_NO_VALUE_REASON_DIFFERENCE
+	NoValueReasonNoMatchedNodes      NoValueReason = C.FIFTYONE_DEGREES_RESULTS_NO_VALUE_REASON_NO_MATCHED_NODES
+	NoValueReasonInvalidProperty     NoValueReason = C.FIFTYONE_DEGREES_RESULTS_NO_VALUE_REASON_INVALID_PROPERTY
+	NoValueReasonNoResultForProperty NoValueReason = C.FIFTYONE_DEGREES_RESULTS_NO_VALUE_REASON_NO_RESULT_FOR_PROPERTY
+	NoValueReasonNoResults           NoValueReason = C.FIFTYONE_DEGREES_RESULTS_NO_VALUE_REASON_NO_RESULTS
+	NoValueReasonTooManyValues       NoValueReason = C.FIFTYONE_DEGREES_RESULTS_NO_VALUE_REASON_TOO_MANY_VALUES
+	NoValueReasonNullProfile         NoValueReason = C.FIFTYONE_DEGREES_RESULTS_NO_VALUE_REASON_NULL_PROFILE
+	NoValueReasonHighRisk            NoValueReason = C.FIFTYONE_DEGREES_RESULTS_NO_VALUE_REASON_HIGH_RISK
+	NoValueReasonUnknown             NoValueReason = C.FIFTYONE_DEGREES_RESULTS_NO_VALUE_REASON_UNKNOWN
+)
+
+// Sentinel errors for the no value reasons which IP intelligence reports. A
+// *NoValueError matches the sentinel with the same reason when compared with
+// errors.Is, whichever property it refers to.
+var (
+	// ErrNoResults is reported when no IP range matched the input
+	ErrNoResults = &NoValueError{Reason: NoValueReasonNoResults}
+	// ErrInvalidProperty is reported for a property which is not in the data
+	// file tier or was not requested when the engine was created
+	ErrInvalidProperty = &NoValueError{Reason: NoValueReasonInvalidProperty}
+	// ErrNullProfile is reported when the matched range has no profile for
+	// the component the property belongs to
+	ErrNullProfile = &NoValueError{Reason: NoValueReasonNullProfile}
+)
+
+// String returns the name of the reason.
+func (r NoValueReason) String() string {
+	switch r {
+	case NoValueReasonDifference:
+		return "Difference"
+	case NoValueReasonNoMatchedNodes:
+		return "NoMatchedNodes"
+	case NoValueReasonInvalidProperty:
+		return "InvalidProperty"
+	case NoValueReasonNoResultForProperty:
+		return "NoResultForProperty"
+	case NoValueReasonNoResults:
+		return "NoResults"
+	case NoValueReasonTooManyValues:
+		return "TooManyValues"
+	case NoValueReasonNullProfile:
+		return "NullProfile"
+	case NoValueReasonHighRisk:
+		return "HighRisk"
+	default:
+		return "Unknown"
+	}
+}
+
+// Message returns the full description of the reason. This matches the C API
+// fiftyoneDegreesResultsIpiGetNoValueReasonMessage
+func (r NoValueReason) Message() string {
+	return C.GoString(C.ResultsIpiGetNoValueReasonMessage(
+		C.fiftyoneDegreesResultsNoValueReason(r)))
+}
+
+// NoValueError is returned when results hold no values. Property is empty
+// when the results as a whole are empty, for example because no IP range
+// matched, otherwise it names the property which has no values.
+type NoValueError struct {
+	Property string
+	Reason   NoValueReason
+}
+
+// Error returns the description of the reason, prefixed with the property
+// name if there is one.
+func (e *NoValueError) Error() string {
+	if e.Property == "" {
+		return fmt.Sprintf("%s %s", ErrNoMatch, e.Reason.Message())
+	}
+	return fmt.Sprintf("no value for property '%s': %s", e.Property, e.Reason.Message())
+}
+
+// Is reports whether target is a *NoValueError with the same reason, so that
+// errors.Is matches the sentinel errors such as ErrNoResults.
+func (e *NoValueError) Is(target error) bool {
+	t, ok := target.(*NoValueError)
+	return ok && t.Reason == e.Reason
+}
+
+// GetNoValueReason returns the reason the results hold no values for the
+// property at the given required-property index. This matches the C API
+// fiftyoneDegreesResultsIpiGetNoValueReason
+func (r *ResultsIpi) GetNoValueReason(requiredPropertyIndex int) (NoValueReason, error) {
+	exception := NewException()
+	defer exception.Free()
+
+	reason := C.ResultsIpiGetNoValueReason(
+		r.CPtr,
+		C.int(requiredPropertyIndex),
+		exception.CPtr,
+	)
+
+	if err := exception.Err(); err != nil {
+		return NoValueReasonUnknown, err
+	}
+
+	return NoValueReason(reason), nil
+}
+
+// NoValueError returns a *NoValueError with the reason the results hold no
+// values at all, or nil if they hold values. Results are only empty when no
+// result could be added for the input, so the reason is the same for every
+// property, typically NoResults.
+func (r *ResultsIpi) NoValueError() error {
+	if r.CPtr == nil {
+		return &StatusError{Code: StatusNullPointer, Message: "results have been freed"}
+	}
+	if r.HasValues() {
+		return nil
+	}
+	reason, err := r.GetNoValueReason(0)
+	if err != nil {
+		return err
+	}
+	return &NoValueError{Reason: reason}
+}
@@ -0,0 +1,107 @@
+/* *********************************************************************
+ * This Original Work is copyright of 51 Degrees Mobile Experts Limited.
+ * Copyright 2019 51 Degrees Mobile Experts Limited, 5 Charlotte Close,
+ * Caversham, Reading, Berkshire, United Kingdom RG4 7BY.
+ *
+ * This Original Work is licensed under the European Union Public Licence (EUPL)
+ * v.1.2 and is subject to its terms as set out below.
+ *
+ * If a copy of the EUPL was not distributed with this file, You can obtain
+ * one at https://opensource.org/licenses/EUPL-1.2.
+ *
+ * The 'Compatible Licences' set out in the Appendix to the EUPL (as may be
+ * amended by the European Commission) shall be deemed incompatible for
+ * the purposes of the Work and the provisions of the compatibility
+ * clause in Article 5 of the EUPL shall not apply.
+ *
+ * If using the Work as, or as part of, a network application, by
+ * including the attribution notice(s) required under Article 5 of the EUPL
+ * in the end user terms of the application under an appropriate heading,
+ * such notice(s) shall fulfill the requirements of that article.
+ * ********************************************************************* */
+package ipi_interop
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestNoValueError_Is(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		target error
+		want   bool
+	}{
+		{
+			name:   "no results",
+			err:    &NoValueError{Reason: NoValueReasonNoResults},
+			target: ErrNoResults,
+			want:   true,
+		},
+		{
+			name:   "property with null profile",
+			err:    fmt.Errorf("lookup failed: %w", &NoValueError{Property: "Mcc", Reason: NoValueReasonNullProfile}),
+			target: ErrNullProfile,
+			want:   true,
+		},
+		{
+			name:   "different reason",
+			err:    &NoValueError{Property: "Mcc", Reason: NoValueReasonInvalidProperty},
+			target: ErrNullProfile,
+			want:   false,
+		},
+		{
+			name:   "status error is not a no value error",
+			err:    ErrCorruptData,
+			target: ErrNoResults,
+			want:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errors.Is(tt.err, tt.target); got != tt.want {
+				t.Errorf("errors.Is() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNoValueError_Error(t *testing.T) {
+	err := &NoValueError{Reason: NoValueReasonNoResults}
+	if !strings.HasPrefix(err.Error(), ErrNoMatch) {
+		t.Errorf("Error() = %q, want prefix %q", err.Error(), ErrNoMatch)
+	}
+
+	err = &NoValueError{Property: "RegisteredCountry", Reason: NoValueReasonInvalidProperty}
+	if !strings.Contains(err.Error(), "RegisteredCountry") {
+		t.Errorf("Error() = %q, want it to name the property", err.Error())
+	}
+}
+
+func TestNoValueReason_Message(t *testing.T) {
+	reasons := []NoValueReason{
+		NoValueReasonNoResults,
+		NoValueReasonNullProfile,
+		NoValueReasonInvalidProperty,
+		NoValueReasonUnknown,
+	}
+
+	for _, reason := range reasons {
+		t.Run(reason.String(), func(t *testing.T) {
+			if reason.Message() == "" {
+				t.Errorf("Message() is empty for %v", reason)
+			}
+		})
+	}
+}
+
+func TestResultsIpi_NoValueError_Freed(t *testing.T) {
+	results := &ResultsIpi{}
+	if err := results.NoValueError(); !errors.Is(err, ErrNullPointer) {
+		t.Errorf("NoValueError() = %v, want %v", err, ErrNullPointer)
+	}
+}
@@ -0,0 +1,284 @@
+/* *********************************************************************
+ * This Original Work is copyright of 51 Degrees Mobile Experts Limited.
+ * Copyright 2019 51 Degrees Mobile Experts Limited, 5 Charlotte Close,
+ * Caversham, Reading, Berkshire, United Kingdom RG4 7BY.
+ *
+ * This Original Work is licensed under the European Union Public Licence (EUPL)
+ * v.1.2 and is subject to its terms as set out below.
+ *
+ * If a copy of the EUPL was not distributed with this file, You can obtain
+ * one at https://opensource.org/licenses/EUPL-1.2.
+ *
+ * The 'Compatible Licences' set out in the Appendix to the EUPL (as may be
+ * amended by the European Commission) shall be deemed incompatible for
+ * the purposes of the Work and the provisions of the compatibility
+ * clause in Article 5 of the EUPL shall not apply.
+ *
+ * If using the Work as, or as part of, a network application, by
+ * including the attribution notice(s) required under Article 5 of the EUPL
+ * in the end user terms of the application under an appropriate heading,
+ * such notice(s) shall fulfill the requirements of that article.
+ * ********************************************************************* */
+
+package ipi_interop
+
+/*
+#include <stdlib.h>
+#include "ip-intelligence-cxx.h"
+
+// Defined in property_metadata.go.
+char* copyString(
+	fiftyoneDegreesCollection *strings,
+	const fiftyoneDegreesString *value,
+	fiftyoneDegreesCollectionItem *item);
+
+// The key types the C layer uses for the profile offsets and profiles
+// collections, which it does not export. They are not static so that
+// explain.go can declare and use them.
+const fiftyoneDegreesCollectionKeyType integerKeyType = {
+	FIFTYONE_DEGREES_PROPERTY_VALUE_TYPE_INTEGER,
+	sizeof(uint32_t),
+	NULL,
+};
+const fiftyoneDegreesCollectionKeyType profileKeyType = {
+	FIFTYONE_DEGREES_COLLECTION_ENTRY_TYPE_PROFILE,
+	sizeof(fiftyoneDegreesProfile),
+	fiftyoneDegreesProfileGetFinalSize,
+};
+
+// profileValue is a value of a profile with the property it relates to. The
+// strings are copies which must be freed.
+typedef struct profile_value_t {
+	char *property;
+	char *value;
+	byte valueType;
+} profileValue;
+
+// valueString returns the value written as a string the same way lookups
+// write it, which the caller must free, or NULL if it cannot be read. It is not
+// static so that property_values.go can declare and use it.
+char* valueString(
+	fiftyoneDegreesDataSetIpi *dataSet,
+	const fiftyoneDegreesValue *value,
+	fiftyoneDegreesException *exception) {
+	fiftyoneDegreesCollectionItem item;
+	const fiftyoneDegreesStoredBinaryValue *content;
+	fiftyoneDegreesPropertyValueType storedType;
+	char *buffer = NULL;
+	size_t length = 64;
+
+	storedType = fiftyoneDegreesPropertyGetStoredTypeByIndex(
+		dataSet->propertyTypes, (uint32_t)value->propertyIndex, exception);
+	if (FIFTYONE_DEGREES_EXCEPTION_FAILED) {
+		return NULL;
+	}
+	fiftyoneDegreesDataReset(&item.data);
+	content = fiftyoneDegreesValueGetContent(
+		dataSet->strings, value, storedType, &item, exception);
+	if (content == NULL) {
+		return NULL;
+	}
+
+	// Write the value, growing the buffer once if it was too short.
+	while (buffer == NULL && FIFTYONE_DEGREES_EXCEPTION_OKAY) {
+		buffer = (char*)malloc(length);
+		if (buffer == NULL) {
+			break;
+		}
+		fiftyoneDegreesStringBuilder builder = { buffer, length };
+		fiftyoneDegreesStringBuilderInit(&builder);
+		fiftyoneDegreesStringBuilderAddStringValue(
+			&builder,
+			content,
+			storedType,
+			FIFTYONE_DEGREES_MAX_DOUBLE_DECIMAL_PLACES,
+			exception);
+		fiftyoneDegreesStringBuilderComplete(&builder);
+		if (builder.full) {
+			free(buffer);
+			buffer = NULL;
+			length = builder.added + 2;
+		}
+	}
+	if (buffer != NULL && FIFTYONE_DEGREES_EXCEPTION_FAILED) {
+		free(buffer);
+		buffer = NULL;
+	}
+
+	FIFTYONE_DEGREES_COLLECTION_RELEASE(dataSet->strings, &item);
+	return buffer;
+}
+
+// profileValues returns the values of the profile, setting count to the
+// number returned. The array and the strings in it must be freed. It is not
+// static so that explain.go can declare and use it.
+profileValue* profileValues(
+	fiftyoneDegreesDataSetIpi *dataSet,
+	const fiftyoneDegreesProfile *profile,
+	uint32_t *count,
+	fiftyoneDegreesException *exception) {
+	fiftyoneDegreesCollectionItem valueItem, propertyItem, item;
+	const fiftyoneDegreesValue *value;
+	const fiftyoneDegreesProperty *property;
+	const uint32_t *valueIndexes;
+	profileValue *values;
+	uint32_t i;
+
+	*count = 0;
+	values = (profileValue*)calloc(
+		profile->valueCount > 0 ? profile->valueCount : 1,
+		sizeof(profileValue));
+	if (values == NULL) {
+		return NULL;
+	}
+	valueIndexes = (const uint32_t*)(profile + 1);
+	for (i = 0; i < profile->valueCount; i++) {
+		fiftyoneDegreesDataReset(&valueItem.data);
+		value = fiftyoneDegreesValueGet(
+			dataSet->values, valueIndexes[i], &valueItem, exception);
+		if (value == NULL) {
+			break;
+		}
+		fiftyoneDegreesDataReset(&propertyItem.data);
+		property = fiftyoneDegreesPropertyGet(
+			dataSet->properties,
+			(uint32_t)value->propertyIndex,
+			&propertyItem,
+			exception);
+		if (property != NULL) {
+			fiftyoneDegreesDataReset(&item.data);
+			values[*count].property = copyString(
+				dataSet->strings,
+				fiftyoneDegreesPropertyGetName(
+					dataSet->strings, property, &item, exception),
+				&item);
+			values[*count].valueType = property->valueType;
+			values[*count].value = valueString(dataSet, value, exception);
+			(*count)++;
+			FIFTYONE_DEGREES_COLLECTION_RELEASE(
+				dataSet->properties, &propertyItem);
+		}
+		FIFTYONE_DEGREES_COLLECTION_RELEASE(dataSet->values, &valueItem);
+	}
+	return values;
+}
+
+// isProfileOffset returns true if a profile starts at the offset in the
+// profiles collection, which is the case for the offsets listed in the
+// profile offsets collection. The profiles follow each other in the profiles
+// collection, so the offsets are in ascending order and can be searched.
+static bool isProfileOffset(
+	fiftyoneDegreesDataSetIpi *dataSet,
+	uint32_t offset,
+	fiftyoneDegreesException *exception) {
+	fiftyoneDegreesCollectionItem item;
+	fiftyoneDegreesCollectionKey key = { 0, &integerKeyType };
+	uint32_t lower = 0, middle, value,
+		upper = fiftyoneDegreesCollectionGetCount(dataSet->profileOffsets);
+
+	while (lower < upper) {
+		middle = lower + (upper - lower) / 2;
+		key.indexOrOffset.index = middle;
+		fiftyoneDegreesDataReset(&item.data);
+		if (dataSet->profileOffsets->get(
+			dataSet->profileOffsets, &key, &item, exception) == NULL ||
+			FIFTYONE_DEGREES_EXCEPTION_FAILED) {
+			return false;
+		}
+		value = *(const uint32_t*)item.data.ptr;
+		FIFTYONE_DEGREES_COLLECTION_RELEASE(dataSet->profileOffsets, &item);
+		if (value == offset) {
+			return true;
+		}
+		if (value < offset) {
+			lower = middle + 1;
+		} else {
+			upper = middle;
+		}
+	}
+	return false;
+}
+
+// getProfileValues returns the values of the profile at the offset in the
+// profiles collection, setting count to the number returned. It returns NULL
+// if no profile starts at the offset. The array and the strings in it must be
+// freed.
+static profileValue* getProfileValues(
+	fiftyoneDegreesDataSetIpi *dataSet,
+	uint32_t offset,
+	uint32_t *count,
+	fiftyoneDegreesException *exception) {
+	fiftyoneDegreesCollectionItem item;
+	fiftyoneDegreesCollectionKey key = { offset, &profileKeyType };
+	const fiftyoneDegreesProfile *profile;
+	profileValue *values;
+
+	*count = 0;
+	// An offset which is not the start of a profile would be read as one.
+	if (isProfileOffset(dataSet, offset, exception) == false) {
+		return NULL;
+	}
+	fiftyoneDegreesDataReset(&item.data);
+	profile = (const fiftyoneDegreesProfile*)dataSet->profiles->get(
+		dataSet->profiles, &key, &item, exception);
+	if (profile == NULL || FIFTYONE_DEGREES_EXCEPTION_FAILED) {
+		return NULL;
+	}
+	values = profileValues(dataSet, profile, count, exception);
+	FIFTYONE_DEGREES_COLLECTION_RELEASE(dataSet->profiles, &item);
+	return values;
+}
+*/
+import "C"
+import (
+	"fmt"
+	"unsafe"
+)
+
+// GetProfileValues returns the values of the profile at profileOffset in the
+// profiles collection of the manager's current data set, for every property of
+// the profile's component including those which were not requested. The
+// offsets of profiles are given by ResultsIpi.MatchedProfiles,
+// TracedProfile.Offset and Component.DefaultProfileOffset; reduced size data
+// files, which this package is built for by default, do not contain profile
+// ids to look profiles up by. The values are converted to the property's value
+// type the same way lookups convert them, apart from the raw WKB bytes of a
+// Geometry which are not set, and have a weight of 1.0. An error is returned
+// if no profile starts at the offset.
+func GetProfileValues(manager *ResourceManager, profileOffset uint32) (Values, error) {
+	cDataSet := (*C.DataSetIpi)(unsafe.Pointer(C.DataSetGet(manager.CPtr)))
+	defer C.DataSetRelease((*C.DataSetBase)(unsafe.Pointer(cDataSet)))
+
+	exception := NewException()
+	defer exception.Free()
+
+	var count C.uint32_t
+	cValues := C.getProfileValues(cDataSet, C.uint32_t(profileOffset), &count, exception.CPtr)
+	if cValues == nil {
+		if err := exception.Err(); err != nil {
+			return nil, err
+		}
+		return nil, &StatusError{Code: StatusInvalidInput, Message: fmt.Sprintf(ErrProfileNotFound, profileOffset)}
+	}
+	values := takeProfileValues(cValues, count, 1.0)
+	if err := exception.Err(); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// takeProfileValues converts the values returned by the C layer for a
+// profile, giving each the weight, and frees them.
+func takeProfileValues(cValues *C.profileValue, count C.uint32_t, weight float64) Values {
+	defer C.free(unsafe.Pointer(cValues))
+
+	values := make(Values)
+	for _, cValue := range unsafe.Slice(cValues, count) {
+		property, value := takeString(cValue.property), takeString(cValue.value)
+		if property == "" || cValue.value == nil {
+			continue
+		}
+		values.AppendWithWeight(property, decodeValue(PropertyValueType(cValue.valueType), value), weight)
+	}
+	return values
+}
@@ -0,0 +1,81 @@
+/* *********************************************************************
+ * This Original Work is copyright of 51 Degrees Mobile Experts Limited.
+ * Copyright 2019 51 Degrees Mobile Experts Limited, 5 Charlotte Close,
+ * Caversham, Reading, Berkshire, United Kingdom RG4 7BY.
+ *
+ * This Original Work is licensed under the European Union Public Licence (EUPL)
+ * v.1.2 and is subject to its terms as set out below.
+ *
+ * If a copy of the EUPL was not distributed with this file, You can obtain
+ * one at https://opensource.org/licenses/EUPL-1.2.
+ *
+ * The 'Compatible Licences' set out in the Appendix to the EUPL (as may be
+ * amended by the European Commission) shall be deemed incompatible for
+ * the purposes of the Work and the provisions of the compatibility
+ * clause in Article 5 of the EUPL shall not apply.
+ *
+ * If using the Work as, or as part of, a network application, by
+ * including the attribution notice(s) required under Article 5 of the EUPL
+ * in the end user terms of the application under an appropriate heading,
+ * such notice(s) shall fulfill the requirements of that article.
+ * ********************************************************************* */
+
+package ipi_interop
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestGetProfileValues(t *testing.T) {
+	manager := newTestManager(t)
+
+	explanation, err := Explain(manager, "8.8.8.8")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	checked := 0
+	for _, component := range explanation.Components {
+		for _, profile := range component.Profiles {
+			values, err := GetProfileValues(manager, profile.Offset)
+			if err != nil {
+				t.Fatalf("Expected the values of the profile at %d, got %v", profile.Offset, err)
+			}
+			if got, want := propertyNames(values), propertyNames(profile.Values); !reflect.DeepEqual(got, want) {
+				t.Errorf("Expected properties %v for the profile at %d, got %v", want, profile.Offset, got)
+			}
+			for property, weighted := range values {
+				for _, value := range weighted {
+					if value.Weight != 1.0 {
+						t.Errorf("Expected %s to have a weight of 1, got %v", property, value.Weight)
+					}
+				}
+			}
+
+			// The offset of a profile plus one is inside the profile rather
+			// than the start of one.
+			_, err = GetProfileValues(manager, profile.Offset+1)
+			var statusErr *StatusError
+			if !errors.As(err, &statusErr) || statusErr.Code != StatusInvalidInput {
+				t.Errorf("Expected a StatusInvalidInput error for offset %d, got %v", profile.Offset+1, err)
+			}
+			checked++
+		}
+	}
+	if checked == 0 {
+		t.Skip("No profiles matched 8.8.8.8 in the data file")
+	}
+}
+
+// propertyNames returns the properties of the values in order.
+func propertyNames(values Values) []string {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,173 @@
+/* *********************************************************************
+ * This Original Work is copyright of 51 Degrees Mobile Experts Limited.
+ * Copyright 2019 51 Degrees Mobile Experts Limited, 5 Charlotte Close,
+ * Caversham, Reading, Berkshire, United Kingdom RG4 7BY.
+ *
+ * This Original Work is licensed under the European Union Public Licence (EUPL)
+ * v.1.2 and is subject to its terms as set out below.
+ *
+ * If a copy of the EUPL was not distributed with this file, You can obtain
+ * one at https://opensource.org/licenses/EUPL-1.2.
+ *
+ * The 'Compatible Licences' set out in the Appendix to the EUPL (as may be
+ * amended by the European Commission) shall be deemed incompatible for
+ * the purposes of the Work and the provisions of the compatibility
+ * clause in Article 5 of the EUPL shall not apply.
+ *
+ * If using the Work as, or as part of, a network application, by
+ * including the attribution notice(s) required under Article 5 of the EUPL
+ * in the end user terms of the application under an appropriate heading,
+ * such notice(s) shall fulfill the requirements of that article.
+ * ********************************************************************* */
+
+package ipi_interop
+
+/*
+#include <stdlib.h>
+#include "ip-intelligence-cxx.h"
+
+// propertyMetadata is the metadata of a property. The strings are copies
+// which must be freed.
+typedef struct property_metadata_t {
+	char *name;
+	char *description;
+	char *category;
+	char *url;
+	char *component;
+	byte valueType;
+	bool isList;
+	bool isObsolete;
+} propertyMetadata;
+
+// copyString returns a copy of the string, releasing the item holding it. It
+// is not static so that the other files reading strings from the data set can
+// declare and use it.
+char* copyString(
+	fiftyoneDegreesCollection *strings,
+	const fiftyoneDegreesString *value,
+	fiftyoneDegreesCollectionItem *item) {
+	char *copy = NULL;
+	if (value != NULL) {
+		copy = strdup(&value->value);
+		FIFTYONE_DEGREES_COLLECTION_RELEASE(strings, item);
+	}
+	fiftyoneDegreesDataReset(&item->data);
+	return copy;
+}
+
+// getPropertyMetadata fills metadata with the metadata of the property at
+// index in the properties collection of the data set, returning false if the
+// property cannot be read.
+static bool getPropertyMetadata(
+	fiftyoneDegreesDataSetIpi *dataSet,
+	uint32_t index,
+	propertyMetadata *metadata,
+	fiftyoneDegreesException *exception) {
+	fiftyoneDegreesCollectionItem propertyItem, item;
+	fiftyoneDegreesComponent *component;
+	const fiftyoneDegreesProperty *property;
+
+	fiftyoneDegreesDataReset(&propertyItem.data);
+	property = fiftyoneDegreesPropertyGet(
+		dataSet->properties, index, &propertyItem, exception);
+	if (property == NULL) {
+		return false;
+	}
+
+	metadata->valueType = property->valueType;
+	metadata->isList = property->isList != 0;
+	metadata->isObsolete = property->isObsolete != 0;
+
+	fiftyoneDegreesDataReset(&item.data);
+	metadata->name = copyString(dataSet->strings, fiftyoneDegreesPropertyGetName(
+		dataSet->strings, property, &item, exception), &item);
+	metadata->description = copyString(dataSet->strings, fiftyoneDegreesPropertyGetDescription(
+		dataSet->strings, property, &item, exception), &item);
+	metadata->category = copyString(dataSet->strings, fiftyoneDegreesPropertyGetCategory(
+		dataSet->strings, property, &item, exception), &item);
+	metadata->url = copyString(dataSet->strings, fiftyoneDegreesPropertyGetUrl(
+		dataSet->strings, property, &item, exception), &item);
+
+	metadata->component = NULL;
+	if (property->componentIndex < dataSet->componentsList.count) {
+		component = (fiftyoneDegreesComponent*)
+			dataSet->componentsList.items[property->componentIndex].data.ptr;
+		metadata->component = copyString(dataSet->strings, fiftyoneDegreesComponentGetName(
+			dataSet->strings, component, &item, exception), &item);
+	}
+
+	FIFTYONE_DEGREES_COLLECTION_RELEASE(dataSet->properties, &propertyItem);
+	return true;
+}
+*/
+import "C"
+import "unsafe"
+
+// PropertyMetadata describes a property in the data file.
+type PropertyMetadata struct {
+	Name        string            // Name of the property, e.g. RegisteredCountry
+	ValueType   PropertyValueType // Type the data file declares for the property's values
+	Weighted    bool              // Whether the values carry a weight, see PropertyValueType.IsWeighted
+	List        bool              // Whether the property can have more than one value
+	Obsolete    bool              // Whether the property will be removed from future data files
+	Description string            // Description of the property
+	Url         string            // URL of further information about the property
+	Category    string            // Category the property belongs to
+	Component   string            // Name of the component the property belongs to, e.g. Location
+	Requested   bool              // Whether the property was requested, so lookups return its values
+}
+
+// GetPropertyMetadata returns the metadata of every property in the
+// manager's current data set, in the order of the data file, including those
+// which were not requested. It is read from the properties collection the same
+// way GetPropertyValueTypes reads the value types.
+func GetPropertyMetadata(manager *ResourceManager) []PropertyMetadata {
+	cDataSet := (*C.DataSetIpi)(unsafe.Pointer(C.DataSetGet(manager.CPtr)))
+	defer C.DataSetRelease((*C.DataSetBase)(unsafe.Pointer(cDataSet)))
+
+	requested := make(map[string]bool)
+	for _, name := range availablePropertyNames(cDataSet) {
+		requested[name] = true
+	}
+
+	exception := NewException()
+	defer exception.Free()
+
+	count := int(cDataSet.properties.count)
+	properties := make([]PropertyMetadata, 0, count)
+	for i := 0; i < count; i++ {
+		var cMetadata C.propertyMetadata
+		exception.Clear()
+		if !C.getPropertyMetadata(cDataSet, C.uint32_t(i), &cMetadata, exception.CPtr) {
+			continue
+		}
+
+		valueType := PropertyValueType(cMetadata.valueType)
+		metadata := PropertyMetadata{
+			Name:        takeString(cMetadata.name),
+			ValueType:   valueType,
+			Weighted:    valueType.IsWeighted(),
+			List:        bool(cMetadata.isList),
+			Obsolete:    bool(cMetadata.isObsolete),
+			Description: takeString(cMetadata.description),
+			Url:         takeString(cMetadata.url),
+			Category:    takeString(cMetadata.category),
+			Component:   takeString(cMetadata.component),
+		}
+		if metadata.Name == "" {
+			continue
+		}
+		metadata.Requested = requested[metadata.Name]
+		properties = append(properties, metadata)
+	}
+	return properties
+}
+
+// takeString returns a Go copy of a string copied by the C layer, freeing it.
+func takeString(cString *C.char) string {
+	if cString == nil {
+		return ""
+	}
+	defer C.free(unsafe.Pointer(cString))
+	return C.GoString(cString)
+}
@@ -0,0 +1,70 @@
+/* *********************************************************************
+ * This Original Work is copyright of 51 Degrees Mobile Experts Limited.
+ * Copyright 2019 51 Degrees Mobile Experts Limited, 5 Charlotte Close,
+ * Caversham, Reading, Berkshire, United Kingdom RG4 7BY.
+ *
+ * This Original Work is licensed under the European Union Public Licence (EUPL)
+ * v.1.2 and is subject to its terms as set out below.
+ *
+ * If a copy of the EUPL was not distributed with this file, You can obtain
+ * one at https://opensource.org/licenses/EUPL-1.2.
+ *
+ * The 'Compatible Licences' set out in the Appendix to the EUPL (as may be
+ * amended by the European Commission) shall be deemed incompatible for
+ * the purposes of the Work and the provisions of the compatibility
+ * clause in Article 5 of the EUPL shall not apply.
+ *
+ * If using the Work as, or as part of, a network application, by
+ * including the attribution notice(s) required under Article 5 of the EUPL
+ * in the end user terms of the application under an appropriate heading,
+ * such notice(s) shall fulfill the requirements of that article.
+ * ********************************************************************* */
+
+package ipi_interop
+
+import (
+	"os"
+	"testing"
+)
+
+func TestGetPropertyMetadata(t *testing.T) {
+	manager := newTestManager(t)
+
+	properties := GetPropertyMetadata(manager)
+	if len(properties) == 0 {
+		t.Fatal("Expected properties, got none")
+	}
+	names := make(map[string]bool)
+	for _, property := range properties {
+		if names[property.Name] {
+			t.Errorf("Expected property names to be unique, got %s twice", property.Name)
+		}
+		names[property.Name] = true
+		if property.Component == "" {
+			t.Errorf("Expected %s to belong to a component", property.Name)
+		}
+		if property.Weighted != property.ValueType.IsWeighted() {
+			t.Errorf("Expected %s to be weighted %v, got %v", property.Name, property.ValueType.IsWeighted(), property.Weighted)
+		}
+		// The manager was loaded with every property.
+		if !property.Requested {
+			t.Errorf("Expected %s to be requested", property.Name)
+		}
+	}
+}
+
+func TestGetPropertyMetadata_Requested(t *testing.T) {
+	requested := GetPropertyMetadata(newTestManager(t))[0].Name
+
+	manager := NewResourceManager()
+	defer manager.Free()
+	if err := InitManagerFromFile(manager, *NewConfigIpi(InMemory), requested, os.Getenv("DATA_FILE")); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	for _, property := range GetPropertyMetadata(manager) {
+		if property.Requested != (property.Name == requested) {
+			t.Errorf("Expected %s to be requested %v, got %v", property.Name, property.Name == requested, property.Requested)
+		}
+	}
+}
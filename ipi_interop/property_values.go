@@ -0,0 +1,175 @@
+/* *********************************************************************
+ * This Original Work is copyright of 51 Degrees Mobile Experts Limited.
+ * Copyright 2019 51 Degrees Mobile Experts Limited, 5 Charlotte Close,
+ * Caversham, Reading, Berkshire, United Kingdom RG4 7BY.
+ *
+ * This Original Work is licensed under the European Union Public Licence (EUPL)
+ * v.1.2 and is subject to its terms as set out below.
+ *
+ * If a copy of the EUPL was not distributed with this file, You can obtain
+ * one at https://opensource.org/licenses/EUPL-1.2.
+ *
+ * The 'Compatible Licences' set out in the Appendix to the EUPL (as may be
+ * amended by the European Commission) shall be deemed incompatible for
+ * the purposes of the Work and the provisions of the compatibility
+ * clause in Article 5 of the EUPL shall not apply.
+ *
+ * If using the Work as, or as part of, a network application, by
+ * including the attribution notice(s) required under Article 5 of the EUPL
+ * in the end user terms of the application under an appropriate heading,
+ * such notice(s) shall fulfill the requirements of that article.
+ * ********************************************************************* */
+
+package ipi_interop
+
+/*
+#include <stdlib.h>
+#include "ip-intelligence-cxx.h"
+
+// Defined in property_metadata.go.
+char* copyString(
+	fiftyoneDegreesCollection *strings,
+	const fiftyoneDegreesString *value,
+	fiftyoneDegreesCollectionItem *item);
+
+// Defined in profile_values.go.
+char* valueString(
+	fiftyoneDegreesDataSetIpi *dataSet,
+	const fiftyoneDegreesValue *value,
+	fiftyoneDegreesException *exception);
+
+// propertyValue is a possible value of a property. The strings are copies
+// which must be freed.
+typedef struct property_value_t {
+	char *value;
+	char *description;
+	char *url;
+} propertyValue;
+
+// getPropertyValues returns every value in the values collection of the data
+// set which relates to the named property, setting count to the number
+// returned and valueType to the type of the property. It returns NULL if there
+// is no such property. The array and the strings in it must be freed.
+static propertyValue* getPropertyValues(
+	fiftyoneDegreesDataSetIpi *dataSet,
+	const char *name,
+	uint32_t *count,
+	byte *valueType,
+	fiftyoneDegreesException *exception) {
+	fiftyoneDegreesCollectionItem propertyItem, valueItem, item;
+	const fiftyoneDegreesProperty *property;
+	const fiftyoneDegreesValue *value;
+	propertyValue *values;
+	uint32_t first, last, i;
+
+	*count = 0;
+	fiftyoneDegreesDataReset(&propertyItem.data);
+	property = fiftyoneDegreesPropertyGetByName(
+		dataSet->properties, dataSet->strings, name, &propertyItem, exception);
+	if (property == NULL) {
+		return NULL;
+	}
+	*valueType = property->valueType;
+	first = property->firstValueIndex;
+	last = property->lastValueIndex;
+
+	values = (propertyValue*)calloc(
+		last >= first ? last - first + 1 : 1,
+		sizeof(propertyValue));
+	if (values != NULL) {
+		for (i = first; last >= first && i <= last; i++) {
+			fiftyoneDegreesDataReset(&valueItem.data);
+			value = fiftyoneDegreesValueGet(
+				dataSet->values, i, &valueItem, exception);
+			if (value == NULL) {
+				break;
+			}
+			values[*count].value = valueString(dataSet, value, exception);
+#ifndef FIFTYONE_DEGREES_REDUCED_FILE
+			// Descriptions are not included in reduced size data files.
+			if (value->descriptionOffset >= 0) {
+				fiftyoneDegreesDataReset(&item.data);
+				values[*count].description = copyString(
+					dataSet->strings,
+					fiftyoneDegreesValueGetDescription(
+						dataSet->strings, value, &item, exception),
+					&item);
+			}
+#endif
+			fiftyoneDegreesDataReset(&item.data);
+			values[*count].url = copyString(
+				dataSet->strings,
+				fiftyoneDegreesValueGetUrl(
+					dataSet->strings, value, &item, exception),
+				&item);
+			(*count)++;
+			FIFTYONE_DEGREES_COLLECTION_RELEASE(dataSet->values, &valueItem);
+			if (i == UINT32_MAX) {
+				break;
+			}
+		}
+	}
+
+	FIFTYONE_DEGREES_COLLECTION_RELEASE(dataSet->properties, &propertyItem);
+	return values;
+}
+*/
+import "C"
+import (
+	"fmt"
+	"unsafe"
+)
+
+// PropertyValue is a value which the data file can return for a property.
+type PropertyValue struct {
+	Value       interface{} // Value converted to the property's value type, as lookups convert it
+	Description string      // Description of the value, if the data file has one
+	Url         string      // URL of further information about the value, if the data file has one
+}
+
+// GetPropertyValues returns every distinct value the manager's current data
+// set can return for the named property, in the order of the data file,
+// whether or not the property was requested. The values are converted the
+// same way as GetProfileValues converts them. An error is returned if the data
+// set has no property with the name.
+func GetPropertyValues(manager *ResourceManager, property string) ([]PropertyValue, error) {
+	cDataSet := (*C.DataSetIpi)(unsafe.Pointer(C.DataSetGet(manager.CPtr)))
+	defer C.DataSetRelease((*C.DataSetBase)(unsafe.Pointer(cDataSet)))
+
+	exception := NewException()
+	defer exception.Free()
+
+	cName := C.CString(property)
+	defer C.free(unsafe.Pointer(cName))
+
+	var count C.uint32_t
+	var valueType C.byte
+	cValues := C.getPropertyValues(cDataSet, cName, &count, &valueType, exception.CPtr)
+	if cValues == nil {
+		if err := exception.Err(); err != nil {
+			return nil, err
+		}
+		return nil, &StatusError{Code: StatusInvalidInput, Message: fmt.Sprintf(ErrPropertyNotFound, property)}
+	}
+	defer C.free(unsafe.Pointer(cValues))
+
+	seen := make(map[string]bool)
+	values := make([]PropertyValue, 0, int(count))
+	for _, cValue := range unsafe.Slice(cValues, count) {
+		value := PropertyValue{
+			Description: takeString(cValue.description),
+			Url:         takeString(cValue.url),
+		}
+		name := takeString(cValue.value)
+		if cValue.value == nil || seen[name] {
+			continue
+		}
+		seen[name] = true
+		value.Value = decodeValue(PropertyValueType(valueType), name)
+		values = append(values, value)
+	}
+	if err := exception.Err(); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
@@ -0,0 +1,72 @@
+/* *********************************************************************
+ * This Original Work is copyright of 51 Degrees Mobile Experts Limited.
+ * Copyright 2019 51 Degrees Mobile Experts Limited, 5 Charlotte Close,
+ * Caversham, Reading, Berkshire, United Kingdom RG4 7BY.
+ *
+ * This Original Work is licensed under the European Union Public Licence (EUPL)
+ * v.1.2 and is subject to its terms as set out below.
+ *
+ * If a copy of the EUPL was not distributed with this file, You can obtain
+ * one at https://opensource.org/licenses/EUPL-1.2.
+ *
+ * The 'Compatible Licences' set out in the Appendix to the EUPL (as may be
+ * amended by the European Commission) shall be deemed incompatible for
+ * the purposes of the Work and the provisions of the compatibility
+ * clause in Article 5 of the EUPL shall not apply.
+ *
+ * If using the Work as, or as part of, a network application, by
+ * including the attribution notice(s) required under Article 5 of the EUPL
+ * in the end user terms of the application under an appropriate heading,
+ * such notice(s) shall fulfill the requirements of that article.
+ * ********************************************************************* */
+
+package ipi_interop
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestGetPropertyValues(t *testing.T) {
+	manager := newTestManager(t)
+
+	var property string
+	for _, metadata := range GetPropertyMetadata(manager) {
+		if metadata.ValueType == StringValueType {
+			property = metadata.Name
+			break
+		}
+	}
+	if property == "" {
+		t.Skip("No string property in the data file")
+	}
+
+	values, err := GetPropertyValues(manager, property)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(values) == 0 {
+		t.Fatalf("Expected values for %s, got none", property)
+	}
+	seen := make(map[string]bool)
+	for _, value := range values {
+		name, ok := value.Value.(string)
+		if !ok {
+			t.Fatalf("Expected %s values to be strings, got %T", property, value.Value)
+		}
+		if seen[name] {
+			t.Errorf("Expected %s values to be distinct, got %q twice", property, name)
+		}
+		seen[name] = true
+	}
+}
+
+func TestGetPropertyValues_UnknownProperty(t *testing.T) {
+	manager := newTestManager(t)
+
+	_, err := GetPropertyValues(manager, "NotAProperty")
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.Code != StatusInvalidInput {
+		t.Errorf("Expected a StatusInvalidInput error, got %v", err)
+	}
+}
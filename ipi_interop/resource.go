@@ -28,14 +28,31 @@ package ipi_interop
 import "C"
 import (
 	"runtime"
+	"sync"
+	"sync/atomic"
 )
 
 // ResourceManagers wraps around a pointer to a value of C ResourceManager
 // structure
+//
+// The manager is reference counted. It starts with one reference, owned by
+// whoever created it and released by Free, and every ResultsIpi created from
+// it holds another until it is freed. The native resources are only freed
+// once the last reference is released, so a manager can be replaced and freed
+// while lookups which started with it are still in flight.
 type ResourceManager struct {
 	setHeaders     map[string]([]string) // Headers to be set in a Http response
 	HttpHeaderKeys []EvidenceKey         // Http header keys required by this engine
 	CPtr           *C.ResourceManager    // Pointer to C resource
+
+	config     []byte // Config the manager was initialised with, see ReloadFromFile
+	properties string // Properties the manager was initialised with, see ReloadFromFile
+
+	refs    atomic.Int64 // References to the native resources
+	freed   atomic.Bool  // Whether Free has released the owner's reference
+	onFreeM sync.Mutex   // Guards onFree and done
+	onFree  []func()     // Called once the native resources are freed
+	done    bool         // Whether the native resources have been freed
 }
 
 // Finalizer function for Resource Manager
@@ -48,14 +65,63 @@ func resourceFinalizer(m *ResourceManager) {
 // NewResourceManager creats a new object of ResourceManager
 func NewResourceManager() *ResourceManager {
 	cManager := new(C.ResourceManager)
-	manager := &ResourceManager{nil, nil, cManager}
+	manager := &ResourceManager{CPtr: cManager}
+	manager.refs.Store(1)
 	runtime.SetFinalizer(manager, resourceFinalizer)
 	return manager
 }
 
-// Free frees the native resources allocated in the C layer for a Resource
-// Manager.
+// Acquire takes a reference to the manager which keeps its native resources
+// alive until Release is called. It returns false, without taking a
+// reference, if the resources have already been freed.
+func (manager *ResourceManager) Acquire() bool {
+	for {
+		refs := manager.refs.Load()
+		if refs <= 0 {
+			return false
+		}
+		if manager.refs.CompareAndSwap(refs, refs+1) {
+			return true
+		}
+	}
+}
+
+// Release releases a reference taken with Acquire, freeing the native
+// resources if it was the last one.
+func (manager *ResourceManager) Release() {
+	if manager.refs.Add(-1) == 0 {
+		manager.free()
+	}
+}
+
+// OnFree registers f to be called once the native resources have been freed,
+// that is after Free has been called and every ResultsIpi created from the
+// manager has been freed. f is called straight away if that has already
+// happened.
+func (manager *ResourceManager) OnFree(f func()) {
+	manager.onFreeM.Lock()
+	if !manager.done {
+		manager.onFree = append(manager.onFree, f)
+		manager.onFreeM.Unlock()
+		return
+	}
+	manager.onFreeM.Unlock()
+	f()
+}
+
+// Free releases the reference owned by the creator of the manager. The native
+// resources allocated in the C layer are freed once every ResultsIpi created
+// from the manager has also been freed. Calling Free more than once has no
+// further effect.
 func (manager *ResourceManager) Free() {
+	if manager.freed.CompareAndSwap(false, true) {
+		manager.Release()
+	}
+}
+
+// free frees the native resources allocated in the C layer for a Resource
+// Manager once the last reference has been released.
+func (manager *ResourceManager) free() {
 	if manager.CPtr != nil {
 		C.ResourceManagerFree(manager.CPtr)
 		// If successfully freed the resource manager. Set the pointer to nil.
@@ -68,4 +134,13 @@ func (manager *ResourceManager) Free() {
 	if manager.HttpHeaderKeys != nil {
 		manager.HttpHeaderKeys = nil
 	}
+
+	manager.onFreeM.Lock()
+	onFree := manager.onFree
+	manager.onFree = nil
+	manager.done = true
+	manager.onFreeM.Unlock()
+	for _, f := range onFree {
+		f()
+	}
 }
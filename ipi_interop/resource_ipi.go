@@ -26,7 +26,7 @@ package ipi_interop
 //#include "ip-intelligence-cxx.h"
 import "C"
 import (
-	"fmt"
+	"bytes"
 	"time"
 	"unsafe"
 )
@@ -53,15 +53,100 @@ func InitManagerFromFile(manager *ResourceManager, config ConfigIpi, properties
 	)
 
 	// Check exception
-	if !exp.IsOkay() {
-		return fmt.Errorf(C.GoString(C.ExceptionGetMessage(exp.CPtr)))
+	if err := exp.Err(); err != nil {
+		return err
 	}
 
 	// Check status code
 	if s != C.SUCCESS {
-		cMessage := C.StatusGetMessage(s, cPath)
-		defer C.MemoryStandardFree(unsafe.Pointer(cMessage))
-		return fmt.Errorf(C.GoString(cMessage))
+		return newStatusError(s, cPath)
+	}
+
+	manager.config, manager.properties = configBytes(config), properties
+	return nil
+}
+
+// InitManagerFromMemory initializes a resource manager from a data file which
+// has already been read into memory, for example with go:embed. The data is
+// copied into memory owned by the C layer, which frees it along with the data
+// set, so data can be reused or discarded once this returns. The input
+// properties is a comma separated string list. This matches the C API
+// fiftyoneDegreesIpiInitManagerFromMemory.
+func InitManagerFromMemory(manager *ResourceManager, config ConfigIpi, properties string, data []byte) error {
+	if len(data) == 0 {
+		return &StatusError{Code: StatusCorruptData, Message: ErrDataEmpty}
+	}
+
+	exp := NewException()
+	defer exp.Free()
+
+	propsRequired := NewPropertiesRequired(properties)
+	defer propsRequired.Free()
+
+	// The C layer keeps using the memory for as long as the data set is
+	// active, and frees it with the data set when freeData is set. The copy
+	// of the config is what the data set, and every data set reloaded into
+	// the manager, is configured with; there is no file to copy to a temp one.
+	cConfig := *config.CPtr
+	cConfig.b.freeData = true
+	cConfig.b.useTempFile = false
+
+	cData := C.CBytes(data)
+	s := C.IpiInitManagerFromMemory(
+		manager.CPtr,
+		&cConfig,
+		propsRequired.CPtr,
+		cData,
+		C.FileOffset(len(data)),
+		exp.CPtr,
+	)
+
+	// The data set only takes ownership of the memory once it is initialised.
+	if err := exp.Err(); err != nil {
+		C.free(cData)
+		return err
+	}
+	if s != C.SUCCESS {
+		C.free(cData)
+		return newStatusError(s, nil)
+	}
+
+	manager.config, manager.properties = configBytes(config), properties
+	return nil
+}
+
+// ReloadFromMemory reloads the data set being used by the resource manager
+// using a data file which has already been read into memory. This is
+// corresponding to the C API fiftyoneDegreesIpiReloadManagerFromMemory. As
+// with InitManagerFromMemory the data is copied, and as with ReloadFromFile
+// the new data set is swapped in atomically. The previous data set, and its
+// memory, are freed once the last ResultsIpi using it is freed.
+//
+// The manager must have been initialised with InitManagerFromMemory, so that
+// the C layer frees the memory of the data sets it reloads.
+func (manager *ResourceManager) ReloadFromMemory(data []byte) error {
+	if len(data) == 0 {
+		return &StatusError{Code: StatusCorruptData, Message: ErrDataEmpty}
+	}
+
+	exp := NewException()
+	defer exp.Free()
+
+	cData := C.CBytes(data)
+	s := C.IpiReloadManagerFromMemory(
+		manager.CPtr,
+		cData,
+		C.FileOffset(len(data)),
+		exp.CPtr,
+	)
+
+	if err := exp.Err(); err != nil {
+		C.free(cData)
+		return err
+	}
+	if s != C.SUCCESS {
+		C.free(cData)
+		return newStatusError(s, nil)
 	}
 
 	return nil
@@ -69,35 +154,37 @@ func InitManagerFromFile(manager *ResourceManager, config ConfigIpi, properties
 
 // ReloadFromFile reloads the data set being used by the resource manager using
 // the specified data file location. This is corresponding to the C API
-// fiftyoneDegreesIpiReloadManagerFromFile.
+// fiftyoneDegreesIpiReloadManagerFromFile. The new data set is swapped in
+// atomically, and the previous one is freed once the last ResultsIpi using it
+// is freed. The data set keeps the configuration and properties the manager
+// was initialised with, so a StatusInvalidConfig error is returned, without
+// reloading, if config or properties differ from those.
 func (manager *ResourceManager) ReloadFromFile(config ConfigIpi, properties string, filePath string) error {
+	if manager.config != nil &&
+		(!bytes.Equal(configBytes(config), manager.config) || properties != manager.properties) {
+		return &StatusError{Code: StatusInvalidConfig, Message: ErrReloadConfig}
+	}
+
 	exp := NewException()
 	defer exp.Free()
 
 	cPath := C.CString(filePath)
 	defer C.free(unsafe.Pointer(cPath))
 
-	propsRequired := NewPropertiesRequired(properties)
-	defer propsRequired.Free()
-
-	s := C.IpiInitManagerFromFile(
+	s := C.IpiReloadManagerFromFile(
 		manager.CPtr,
-		config.CPtr,
-		propsRequired.CPtr,
 		cPath,
 		exp.CPtr,
 	)
 
 	// Check exception
-	if !exp.IsOkay() {
-		return fmt.Errorf(C.GoString(C.ExceptionGetMessage(exp.CPtr)))
+	if err := exp.Err(); err != nil {
+		return err
 	}
 
 	// Check status code
 	if s != C.SUCCESS {
-		cMessage := C.StatusGetMessage(s, cPath)
-		defer C.MemoryStandardFree(unsafe.Pointer(cMessage))
-		return fmt.Errorf(C.GoString(cMessage))
+		return newStatusError(s, cPath)
 	}
 
 	return nil
@@ -115,8 +202,8 @@ func (manager *ResourceManager) ReloadFromOriginalFile() error {
 		manager.CPtr,
 		exp.CPtr,
 	)
-	if !exp.IsOkay() {
-		return fmt.Errorf(C.GoString(C.ExceptionGetMessage(exp.CPtr)))
+	if err := exp.Err(); err != nil {
+		return err
 	}
 	return nil
 }
@@ -125,6 +212,22 @@ func GetPublishedDate(manager *ResourceManager) time.Time {
 	cDataSet := (*C.DataSetIpi)(unsafe.Pointer(C.DataSetGet(manager.CPtr)))
 	// Release the dataset
 	defer C.DataSetRelease((*C.DataSetBase)(unsafe.Pointer(cDataSet)))
+	return toTime(cDataSet.header.published)
+}
+
+// GetValidPublishedDate returns the published date in the header of the
+// manager's current data set, or an error matching ErrCorruptData if it is not
+// a real date. GetPublishedDate does not check the date, so returns the zeroed
+// date of a damaged header as a date in the year 0.
+func GetValidPublishedDate(manager *ResourceManager) (time.Time, error) {
+	cDataSet := (*C.DataSetIpi)(unsafe.Pointer(C.DataSetGet(manager.CPtr)))
+	defer C.DataSetRelease((*C.DataSetBase)(unsafe.Pointer(cDataSet)))
 	published := cDataSet.header.published
-	return time.Date(int(published.year), time.Month(published.month), int(published.day), 0, 0, 0, 0, time.UTC)
+	return headerDate(int(published.year), int(published.month), int(published.day))
+}
+
+// configBytes returns the contents of the C config, which the manager keeps to
+// check the config given to ReloadFromFile against.
+func configBytes(config ConfigIpi) []byte {
+	return C.GoBytes(unsafe.Pointer(config.CPtr), C.sizeof_ConfigIpi)
 }
@@ -21,6 +21,12 @@
  * ********************************************************************* */
 package ipi_interop
 
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
 // TODO: uncomment after the file 51Degrees-LiteIpiV41.ipi will be added to the repository
 // Replace
 //func TestInitManagerFromFile(t *testing.T) {
@@ -52,3 +58,85 @@ package ipi_interop
 //		})
 //	}
 //}
+
+func TestInitManagerFromMemory(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "nil data", data: nil},
+		{name: "empty data", data: []byte{}},
+		{name: "corrupt data", data: []byte("not a data file")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := NewResourceManager()
+			defer manager.Free()
+
+			err := InitManagerFromMemory(manager, *NewConfigIpi(InMemory), "", tt.data)
+			if err == nil {
+				t.Errorf("Expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestInitManagerFromMemory_Empty(t *testing.T) {
+	manager := NewResourceManager()
+	defer manager.Free()
+
+	err := InitManagerFromMemory(manager, *NewConfigIpi(InMemory), "", nil)
+	if !errors.Is(err, ErrCorruptData) {
+		t.Errorf("Expected ErrCorruptData, got %v", err)
+	}
+	if err := manager.ReloadFromMemory(nil); !errors.Is(err, ErrCorruptData) {
+		t.Errorf("Expected ErrCorruptData, got %v", err)
+	}
+}
+
+// newTestManager returns a manager loaded with the data file at the path in
+// the DATA_FILE environment variable, which the examples also use, skipping
+// the test when it is not set. The manager is freed when the test finishes.
+func newTestManager(t *testing.T) *ResourceManager {
+	t.Helper()
+	filePath := os.Getenv("DATA_FILE")
+	if filePath == "" {
+		t.Skip("DATA_FILE is not set to an IP Intelligence data file")
+	}
+
+	manager := NewResourceManager()
+	t.Cleanup(manager.Free)
+	if err := InitManagerFromFile(manager, *NewConfigIpi(InMemory), "", filePath); err != nil {
+		t.Fatalf("Failed to load %s: %v", filePath, err)
+	}
+	return manager
+}
+
+func TestResourceManager_ReloadFromFile_DifferentConfig(t *testing.T) {
+	filePath := os.Getenv("DATA_FILE")
+	manager := newTestManager(t)
+
+	tests := []struct {
+		name       string
+		config     *ConfigIpi
+		properties string
+		wantErr    bool
+	}{
+		{name: "same", config: NewConfigIpi(InMemory)},
+		{name: "different config", config: NewConfigIpi(LowMemory), wantErr: true},
+		{name: "different properties", config: NewConfigIpi(InMemory), properties: "RegisteredCountry", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := manager.ReloadFromFile(*tt.config, tt.properties, filePath)
+			if tt.wantErr != errors.Is(err, &StatusError{Code: StatusInvalidConfig}) {
+				t.Errorf("ReloadFromFile() error = %v, want an invalid config error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("ReloadFromFile() error = %v", err)
+			}
+		})
+	}
+}
@@ -153,3 +153,42 @@ func TestResourceManager_FieldModification(t *testing.T) {
 		t.Run(tt.name, tt.test)
 	}
 }
+
+func TestResourceManager_References(t *testing.T) {
+	manager := NewResourceManager()
+
+	freed := 0
+	manager.OnFree(func() { freed++ })
+
+	if !manager.Acquire() {
+		t.Fatal("Acquire() failed on a live manager")
+	}
+
+	// The owner's reference is released, but the acquired one keeps the
+	// native resources alive.
+	manager.Free()
+	manager.Free()
+	if manager.CPtr == nil {
+		t.Error("Free() freed the native resources while a reference is held")
+	}
+	if freed != 0 {
+		t.Errorf("Expected OnFree not to be called yet, got %d calls", freed)
+	}
+
+	manager.Release()
+	if manager.CPtr != nil {
+		t.Error("Release() of the last reference did not free the native resources")
+	}
+	if freed != 1 {
+		t.Errorf("Expected OnFree to be called once, got %d calls", freed)
+	}
+
+	if manager.Acquire() {
+		t.Error("Acquire() succeeded on a freed manager")
+	}
+
+	manager.OnFree(func() { freed++ })
+	if freed != 2 {
+		t.Errorf("Expected OnFree on a freed manager to be called straight away, got %d calls", freed)
+	}
+}
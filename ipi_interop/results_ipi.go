@@ -27,6 +27,7 @@ import "C"
 import (
 	"fmt"
 	"math"
+	"net/netip"
 	"runtime"
 	"unsafe"
 )
@@ -46,12 +47,19 @@ var maxWeighting = uint16Max * uint16Max
 type ResultsIpi struct {
 	CPtr     *C.ResultsIpi
 	CResults *interface{} // Pointer to a slice holding C results
+
+	manager *ResourceManager // Holds a reference to the manager until Free
 }
 
 // NewResultsIpi creates a new ResultsIpi instance using the provided ResourceManager.
 // The instance handles C.ResultsIpi creation and associated memory management.
 // A finalizer is set to ensure resources are explicitly freed.
+// The results hold a reference to the manager until they are freed, so the manager's native
+// resources outlive any call to its Free method. It panics if the manager has already been freed.
 func NewResultsIpi(manager *ResourceManager) *ResultsIpi {
+	if !manager.Acquire() {
+		panic("ERROR: ResultsIpi cannot be created from a ResourceManager which has been freed.")
+	}
 	r := C.ResultsIpiCreate(manager.CPtr)
 
 	var cResults interface{} = (*[math.MaxInt32 / int(C.sizeof_ResultIpi)]C.ResultIpi)(unsafe.Pointer(r.items))[:r.capacity:r.capacity]
@@ -59,6 +67,7 @@ func NewResultsIpi(manager *ResourceManager) *ResultsIpi {
 	res := &ResultsIpi{
 		CPtr:     r,
 		CResults: &cResults,
+		manager:  manager,
 	}
 	runtime.SetFinalizer(res, resultsFinalizer)
 
@@ -81,13 +90,87 @@ func (r *ResultsIpi) ResultsIpiFromIpAddress(ipAddress string) error {
 		exception.CPtr,
 	)
 
-	if !exception.IsOkay() {
-		return fmt.Errorf(C.GoString(C.ExceptionGetMessage(exception.CPtr)))
+	if err := exception.Err(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ResultsIpiFromEvidence processes the evidence and populates the ResultsIpi instance with related data.
+// Query evidence such as "query.client-ip-51d" takes precedence over server evidence such as
+// "server.client-ip", matching the C API fiftyoneDegreesResultsIpiFromEvidence.
+// Returns an error if the operation fails.
+func (r *ResultsIpi) ResultsIpiFromEvidence(evidence *Evidence) error {
+	if evidence == nil || evidence.CPtr == nil {
+		return &StatusError{Code: StatusNullPointer, Message: "evidence is nil or has been freed"}
+	}
+
+	exception := NewException()
+	defer exception.Free()
+
+	C.ResultsIpiFromEvidence(
+		r.CPtr,
+		evidence.CPtr,
+		exception.CPtr,
+	)
+
+	if err := exception.Err(); err != nil {
+		return err
 	}
 
 	return nil
 }
 
+// ResultsIpiFromIpAddressBytes processes the given binary IP address and populates the ResultsIpi instance
+// with related data, without formatting and re-parsing the address as a string. The address must be 4 bytes
+// for IPv4 or 16 bytes for IPv6. IPv4-mapped IPv6 addresses (::ffff:a.b.c.d) are processed as the IPv4 address
+// they map. Returns an error if the operation fails.
+func (r *ResultsIpi) ResultsIpiFromIpAddressBytes(ipAddress []byte) error {
+	ip, ok := normaliseIpAddress(ipAddress)
+	if !ok {
+		return &StatusError{
+			Code:    StatusIncorrectIpAddressFormat,
+			Message: fmt.Sprintf("invalid IP address length: %d bytes", len(ipAddress)),
+		}
+	}
+
+	ipType := C.fiftyoneDegreesIpType(C.IP_TYPE_IPV6)
+	if len(ip) == net4Length {
+		ipType = C.IP_TYPE_IPV4
+	}
+
+	exception := NewException()
+	defer exception.Free()
+
+	C.ResultsIpiFromIpAddress(
+		r.CPtr,
+		(*C.uchar)(unsafe.Pointer(&ip[0])),
+		C.size_t(len(ip)),
+		ipType,
+		exception.CPtr,
+	)
+
+	if err := exception.Err(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// net4Length is the length in bytes of a binary IPv4 address.
+const net4Length = 4
+
+// normaliseIpAddress validates the length of a binary IP address and unmaps IPv4-mapped IPv6 addresses to
+// their 4 byte IPv4 form. It reports false if the address is neither 4 nor 16 bytes long.
+func normaliseIpAddress(ipAddress []byte) ([]byte, bool) {
+	addr, ok := netip.AddrFromSlice(ipAddress)
+	if !ok {
+		return nil, false
+	}
+	return addr.Unmap().AsSlice(), true
+}
+
 // HasValues checks if the ResultsIpi instance contains valid results by verifying whether the C pointer is non-nil and count > 0.
 func (r *ResultsIpi) HasValues() bool {
 	return r.CPtr != nil && r.CPtr.count > 0
@@ -95,10 +178,17 @@ func (r *ResultsIpi) HasValues() bool {
 
 // Free free the resource allocated in the C layer.
 func (results *ResultsIpi) Free() {
+	if results == nil {
+		return
+	}
 	if results.CPtr != nil {
 		C.ResultsIpiFree(results.CPtr)
 		results.CPtr = nil
 	}
+	if results.manager != nil {
+		results.manager.Release()
+		results.manager = nil
+	}
 }
 
 // resultsFinalizer check if C resource has been explicitly
@@ -136,26 +226,35 @@ func (r *ResultsIpi) getPropertyNameSafe(dataSet *C.DataSetIpi, requiredIndex C.
 	return ""
 }
 
-// isPropertyWeighted reports whether the property at the given required index has
-// a weighted value type - i.e. its values carry an intrinsic per-value confidence
-// weight (Mcc and the multi-value location lists). It resolves the required index
-// to the property in the source collection and reads that property's declared
-// value type. On any failure it returns false so the value is treated as
-// unweighted rather than reporting a spurious weight.
-func (r *ResultsIpi) isPropertyWeighted(dataSet *C.DataSetIpi, requiredIndex C.int, exception *Exception) bool {
+// getPropertyValueType returns the value type the data file declares for the
+// property at the given required index. This is the type values are decoded
+// into, and tells whether its values carry an intrinsic per-value confidence
+// weight (Mcc and the multi-value location lists). It resolves the required
+// index to the property in the source collection and reads that property's
+// declared value type. On any failure it returns false so the value is left
+// as the C layer provided it and treated as unweighted rather than reporting
+// a spurious weight.
+func (r *ResultsIpi) getPropertyValueType(dataSet *C.DataSetIpi, requiredIndex C.int, exception *Exception) (PropertyValueType, bool) {
 	propertyIndex := C.fiftyoneDegreesPropertiesGetPropertyIndexFromRequiredIndex(
 		dataSet.b.b.available, requiredIndex)
 	if propertyIndex < 0 {
-		return false
+		return 0, false
 	}
 
+	exception.Clear()
 	valueType := C.fiftyoneDegreesPropertyGetValueType(
 		dataSet.properties, C.uint32_t(propertyIndex), exception.CPtr)
 	if !exception.IsOkay() {
-		return false
+		return 0, false
 	}
 
-	return PropertyValueType(valueType).IsWeighted()
+	return PropertyValueType(valueType), true
+}
+
+// declaredValueType caches the result of getPropertyValueType.
+type declaredValueType struct {
+	valueType PropertyValueType
+	ok        bool
 }
 
 // header mirrors fiftyoneDegreesWeightedValueHeader from the C library. rawWeighting
@@ -182,7 +281,7 @@ func (r *ResultsIpi) GetWeightedValuesByIndexes(indexes []int, propertyNameResol
 		// Allocate C memory for the array
 		cIndexes = (*C.int)(C.malloc(C.size_t(len(indexes)) * C.size_t(unsafe.Sizeof(C.int(0)))))
 		if cIndexes == nil {
-			return nil, fmt.Errorf("failed to allocate memory for indexes")
+			return nil, &StatusError{Code: StatusInsufficientMemory, Message: "failed to allocate memory for indexes"}
 		}
 
 		defer C.free(unsafe.Pointer(cIndexes))
@@ -203,8 +302,8 @@ func (r *ResultsIpi) GetWeightedValuesByIndexes(indexes []int, propertyNameResol
 		nil, exception.CPtr,
 	)
 
-	if !exception.IsOkay() {
-		return nil, fmt.Errorf(C.GoString(C.ExceptionGetMessage(exception.CPtr)))
+	if err := exception.Err(); err != nil {
+		return nil, err
 	}
 
 	// Release the collection
@@ -212,12 +311,18 @@ func (r *ResultsIpi) GetWeightedValuesByIndexes(indexes []int, propertyNameResol
 
 	values := make(Values, collection.itemsCount)
 
-	// Cache the weighted/unweighted decision per required-property index so the
+	// Cache the declared value type per required-property index so the
 	// value-type lookup runs once per property rather than once per value.
-	weightedByReqIndex := make(map[C.int]bool)
+	valueTypes := make(map[C.int]declaredValueType)
 	typeException := NewException()
 	defer typeException.Free()
 
+	// Raw WKB bytes are fetched once per property, after the values
+	// collection has been read.
+	wkb := newWkbMatcher(func(requiredIndex int) [][]byte {
+		return r.getByteArrayValues(C.int(requiredIndex), typeException)
+	})
+
 	// Create a Go slice from the C array
 	headers := unsafe.Slice(collection.items, collection.itemsCount)
 	for _, h := range headers {
@@ -244,6 +349,10 @@ func (r *ResultsIpi) GetWeightedValuesByIndexes(indexes []int, propertyNameResol
 			val = int(weightedInt.value)
 
 		case FloatValueType:
+			// The C layer widens single precision floats to doubles
+			weightedDouble := (*C.fiftyoneDegreesWeightedDouble)(unsafe.Pointer(nextHeader))
+			val = float32(weightedDouble.value)
+
 		case DoubleValueType:
 			// Cast to weighted double and get value
 			weightedDouble := (*C.fiftyoneDegreesWeightedDouble)(unsafe.Pointer(nextHeader))
@@ -252,7 +361,7 @@ func (r *ResultsIpi) GetWeightedValuesByIndexes(indexes []int, propertyNameResol
 		case BooleanValueType:
 			// Cast to weighted boolean and get value
 			weightedBool := (*C.fiftyoneDegreesWeightedBool)(unsafe.Pointer(nextHeader))
-			val = weightedBool.value
+			val = bool(weightedBool.value)
 
 		case ByteValueType:
 			// Cast to weighted byte and get value
@@ -267,18 +376,25 @@ func (r *ResultsIpi) GetWeightedValuesByIndexes(indexes []int, propertyNameResol
 			val = C.GoString(weightedString.value)
 		}
 
+		declared, seen := valueTypes[requiredPropertyIndex]
+		if !seen {
+			declared.valueType, declared.ok = r.getPropertyValueType(dataSet, requiredPropertyIndex, typeException)
+			valueTypes[requiredPropertyIndex] = declared
+		}
+
+		if declared.ok {
+			val = decodeValue(declared.valueType, val)
+			if geometry, ok := val.(Geometry); ok {
+				val = wkb.match(int(requiredPropertyIndex), geometry)
+			}
+		}
+
 		// Weighted properties (e.g. Mcc and the multi-value location lists) carry an
 		// intrinsic per-value weight, so surface their real confidence. Every other
 		// property is unweighted: report full confidence (1.0) rather than 0.0, which
 		// reads as zero confidence.
-		weighted, seen := weightedByReqIndex[requiredPropertyIndex]
-		if !seen {
-			weighted = r.isPropertyWeighted(dataSet, requiredPropertyIndex, typeException)
-			weightedByReqIndex[requiredPropertyIndex] = weighted
-		}
-
 		weight := 1.0
-		if weighted {
+		if declared.ok && declared.valueType.IsWeighted() {
 			weight = float64(nextHeader.rawWeighting) / maxWeighting
 		}
 		values.AppendWithWeight(propName, val, weight)
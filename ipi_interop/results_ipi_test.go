@@ -20,3 +20,66 @@
  * such notice(s) shall fulfill the requirements of that article.
  * ********************************************************************* */
 package ipi_interop
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNormaliseIpAddress(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  []byte
+		want   []byte
+		wantOk bool
+	}{
+		{
+			name:   "IPv4",
+			input:  []byte{185, 28, 167, 77},
+			want:   []byte{185, 28, 167, 77},
+			wantOk: true,
+		},
+		{
+			name:   "IPv6",
+			input:  []byte{0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1},
+			want:   []byte{0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1},
+			wantOk: true,
+		},
+		{
+			name:   "IPv4-mapped IPv6 is unmapped",
+			input:  []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff, 185, 28, 167, 77},
+			want:   []byte{185, 28, 167, 77},
+			wantOk: true,
+		},
+		{
+			name:   "empty",
+			input:  nil,
+			wantOk: false,
+		},
+		{
+			name:   "invalid length",
+			input:  []byte{1, 2, 3},
+			wantOk: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := normaliseIpAddress(tt.input)
+			if ok != tt.wantOk {
+				t.Fatalf("normaliseIpAddress() ok = %v, want %v", ok, tt.wantOk)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("normaliseIpAddress() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResultsIpi_ResultsIpiFromIpAddressBytes_InvalidLength(t *testing.T) {
+	// The length is validated before the C layer is called, so no data set is needed.
+	r := &ResultsIpi{}
+	if err := r.ResultsIpiFromIpAddressBytes([]byte{1, 2, 3}); err == nil {
+		t.Error("expected error for a 3 byte address")
+	}
+}
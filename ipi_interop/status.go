@@ -0,0 +1,128 @@
+/* *********************************************************************
+ * This Original Work is copyright of 51 Degrees Mobile Experts Limited.
+ * Copyright 2019 51 Degrees Mobile Experts Limited, 5 Charlotte Close,
+ * Caversham, Reading, Berkshire, United Kingdom RG4 7BY.
+ *
+ * This Original Work is licensed under the European Union Public Licence (EUPL)
+ * v.1.2 and is subject to its terms as set out below.
+ *
+ * If a copy of the EUPL was not distributed with this file, You can obtain
+ * one at https://opensource.org/licenses/EUPL-1.2.
+ *
+ * The 'Compatible Licences' set out in the Appendix to the EUPL (as may be
+ * amended by the European Commission) shall be deemed incompatible for
+ * the purposes of the Work and the provisions of the compatibility
+ * clause in Article 5 of the EUPL shall not apply.
+ *
+ * If using the Work as, or as part of, a network application, by
+ * including the attribution notice(s) required under Article 5 of the EUPL
+ * in the end user terms of the application under an appropriate heading,
+ * such notice(s) shall fulfill the requirements of that article.
+ * ********************************************************************* */
+
+package ipi_interop
+
+//#include <string.h>
+//#include "ip-intelligence-cxx.h"
+import "C"
+import (
+	"fmt"
+	"unsafe"
+)
+
+// StatusCode mirrors the C enum fiftyoneDegreesStatusCode
+type StatusCode int
+
+// https://github.com/51Degrees/common-cxx/blob/version/4.5/status.h
+const (
+	StatusSuccess                  StatusCode = C.FIFTYONE_DEGREES_STATUS_SUCCESS
+	StatusInsufficientMemory       StatusCode = C.FIFTYONE_DEGREES_STATUS_INSUFFICIENT_MEMORY
+	StatusCorruptData              StatusCode = C.FIFTYONE_DEGREES_STATUS_CORRUPT_DATA
+	StatusIncorrectVersion         StatusCode = C.FIFTYONE_DEGREES_STATUS_INCORRECT_VERSION
+	StatusFileNotFound             StatusCode = C.FIFTYONE_DEGREES_STATUS_FILE_NOT_FOUND
+	StatusFileBusy                 StatusCode = C.FIFTYONE_DEGREES_STATUS_FILE_BUSY
+	StatusFileFailure              StatusCode = C.FIFTYONE_DEGREES_STATUS_FILE_FAILURE
+	StatusPointerOutOfBounds       StatusCode = C.FIFTYONE_DEGREES_STATUS_POINTER_OUT_OF_BOUNDS
+	StatusNullPointer              StatusCode = C.FIFTYONE_DEGREES_STATUS_NULL_POINTER
+	StatusRequiredPropNotPresent   StatusCode = C.FIFTYONE_DEGREES_STATUS_REQ_PROP_NOT_PRESENT
+	StatusProfileEmpty             StatusCode = C.FIFTYONE_DEGREES_STATUS_PROFILE_EMPTY
+	StatusCollectionFailure        StatusCode = C.FIFTYONE_DEGREES_STATUS_COLLECTION_FAILURE
+	StatusFileReadError            StatusCode = C.FIFTYONE_DEGREES_STATUS_FILE_READ_ERROR
+	StatusFilePermissionDenied     StatusCode = C.FIFTYONE_DEGREES_STATUS_FILE_PERMISSION_DENIED
+	StatusInvalidConfig            StatusCode = C.FIFTYONE_DEGREES_STATUS_INVALID_CONFIG
+	StatusInsufficientHandles      StatusCode = C.FIFTYONE_DEGREES_STATUS_INSUFFICIENT_HANDLES
+	StatusIncorrectIpAddressFormat StatusCode = C.FIFTYONE_DEGREES_STATUS_INCORRECT_IP_ADDRESS_FORMAT
+	StatusTempFileError            StatusCode = C.FIFTYONE_DEGREES_STATUS_TEMP_FILE_ERROR
+	StatusInvalidInput             StatusCode = C.FIFTYONE_DEGREES_STATUS_INVALID_INPUT
+	StatusFileTooLarge             StatusCode = C.FIFTYONE_DEGREES_STATUS_FILE_TOO_LARGE
+	StatusNotImplemented           StatusCode = C.FIFTYONE_DEGREES_STATUS_NOT_IMPLEMENTED
+)
+
+// Sentinel errors for the most common status codes. A *StatusError matches the
+// sentinel with the same status code when compared with errors.Is, regardless
+// of the message or the C source location it carries.
+var (
+	ErrInsufficientMemory     = &StatusError{Code: StatusInsufficientMemory, Message: "insufficient memory"}
+	ErrCorruptData            = &StatusError{Code: StatusCorruptData, Message: "corrupt data"}
+	ErrIncorrectVersion       = &StatusError{Code: StatusIncorrectVersion, Message: "incorrect data file version"}
+	ErrFileNotFound           = &StatusError{Code: StatusFileNotFound, Message: "data file not found"}
+	ErrFileBusy               = &StatusError{Code: StatusFileBusy, Message: "data file busy"}
+	ErrFileFailure            = &StatusError{Code: StatusFileFailure, Message: "data file failure"}
+	ErrNullPointer            = &StatusError{Code: StatusNullPointer, Message: "null pointer"}
+	ErrRequiredPropNotPresent = &StatusError{Code: StatusRequiredPropNotPresent, Message: "required properties not present"}
+	ErrCollectionFailure      = &StatusError{Code: StatusCollectionFailure, Message: "collection failure"}
+	ErrInsufficientHandles    = &StatusError{Code: StatusInsufficientHandles, Message: "insufficient handles"}
+	ErrInvalidIpAddress       = &StatusError{Code: StatusIncorrectIpAddressFormat, Message: "incorrect IP address format"}
+	ErrFileTooLarge           = &StatusError{Code: StatusFileTooLarge, Message: "data file too large"}
+	ErrInvalidConfig          = &StatusError{Code: StatusInvalidConfig, Message: "invalid config"}
+	ErrFilePermissionDenied   = &StatusError{Code: StatusFilePermissionDenied, Message: "data file permission denied"}
+	ErrStatusNotImplemented   = &StatusError{Code: StatusNotImplemented, Message: "not implemented"}
+)
+
+// StatusError is an error reported by the C library. It carries the
+// fiftyoneDegreesStatusCode and, when the error was raised through an
+// Exception, the C source file, function and line which raised it.
+type StatusError struct {
+	Code     StatusCode
+	Message  string
+	File     string
+	Function string
+	Line     int
+}
+
+// Error returns the C status message, followed by the C source location if
+// it is known.
+func (e *StatusError) Error() string {
+	if e.File == "" {
+		return e.Message
+	}
+	return fmt.Sprintf("%s (%s at %s:%d)", e.Message, e.Function, e.File, e.Line)
+}
+
+// Is reports whether target is a *StatusError with the same status code, so
+// that errors.Is matches the sentinel errors such as ErrCorruptData.
+func (e *StatusError) Is(target error) bool {
+	t, ok := target.(*StatusError)
+	return ok && t.Code == e.Code
+}
+
+// newStatusError creates a StatusError for a status code returned by the C
+// library. fileName is used in the message of file related status codes and
+// may be nil.
+func newStatusError(status C.fiftyoneDegreesStatusCode, fileName *C.char) *StatusError {
+	return &StatusError{
+		Code:    StatusCode(status),
+		Message: statusMessage(status, fileName),
+	}
+}
+
+// statusMessage returns the message the C library gives for a status code.
+// This matches the C API fiftyoneDegreesStatusGetMessage
+func statusMessage(status C.fiftyoneDegreesStatusCode, fileName *C.char) string {
+	cMessage := C.StatusGetMessage(status, fileName)
+	if cMessage == nil {
+		return fmt.Sprintf("status code %d", int(status))
+	}
+	defer C.MemoryStandardFree(unsafe.Pointer(cMessage))
+	return C.GoString(cMessage)
+}
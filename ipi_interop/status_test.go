@@ -0,0 +1,126 @@
+/* *********************************************************************
+ * This Original Work is copyright of 51 Degrees Mobile Experts Limited.
+ * Copyright 2019 51 Degrees Mobile Experts Limited, 5 Charlotte Close,
+ * Caversham, Reading, Berkshire, United Kingdom RG4 7BY.
+ *
+ * This Original Work is licensed under the European Union Public Licence (EUPL)
+ * v.1.2 and is subject to its terms as set out below.
+ *
+ * If a copy of the EUPL was not distributed with this file, You can obtain
+ * one at https://opensource.org/licenses/EUPL-1.2.
+ *
+ * The 'Compatible Licences' set out in the Appendix to the EUPL (as may be
+ * amended by the European Commission) shall be deemed incompatible for
+ * the purposes of the Work and the provisions of the compatibility
+ * clause in Article 5 of the EUPL shall not apply.
+ *
+ * If using the Work as, or as part of, a network application, by
+ * including the attribution notice(s) required under Article 5 of the EUPL
+ * in the end user terms of the application under an appropriate heading,
+ * such notice(s) shall fulfill the requirements of that article.
+ * ********************************************************************* */
+package ipi_interop
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestStatusError_Is(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		target error
+		want   bool
+	}{
+		{
+			name:   "same code different message",
+			err:    &StatusError{Code: StatusCorruptData, Message: "the data was corrupt", File: "dataset.c", Line: 10},
+			target: ErrCorruptData,
+			want:   true,
+		},
+		{
+			name:   "different code",
+			err:    &StatusError{Code: StatusFileNotFound, Message: "missing"},
+			target: ErrCorruptData,
+			want:   false,
+		},
+		{
+			name:   "wrapped",
+			err:    fmt.Errorf("failed to init manager from file: %w", &StatusError{Code: StatusIncorrectVersion}),
+			target: ErrIncorrectVersion,
+			want:   true,
+		},
+		{
+			name:   "not a status error",
+			err:    errors.New("incorrect data file version"),
+			target: ErrIncorrectVersion,
+			want:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errors.Is(tt.err, tt.target); got != tt.want {
+				t.Errorf("errors.Is() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatusError_Error(t *testing.T) {
+	tests := []struct {
+		name string
+		err  *StatusError
+		want string
+	}{
+		{
+			name: "without location",
+			err:  &StatusError{Code: StatusCorruptData, Message: "corrupt data"},
+			want: "corrupt data",
+		},
+		{
+			name: "with location",
+			err: &StatusError{
+				Code:     StatusIncorrectIpAddressFormat,
+				Message:  "incorrect IP address format",
+				File:     "ipi.c",
+				Function: "ResultsIpiFromIpAddress",
+				Line:     42,
+			},
+			want: "incorrect IP address format (ResultsIpiFromIpAddress at ipi.c:42)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.err.Error(); got != tt.want {
+				t.Errorf("Error() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestException_Err(t *testing.T) {
+	exception := NewException()
+	defer exception.Free()
+
+	if err := exception.Err(); err != nil {
+		t.Fatalf("Err() = %v, want nil for a new exception", err)
+	}
+
+	exception.CPtr.status = 4 // FIFTYONE_DEGREES_STATUS_FILE_NOT_FOUND
+	err := exception.Err()
+	if !errors.Is(err, ErrFileNotFound) {
+		t.Fatalf("Err() = %v, want %v", err, ErrFileNotFound)
+	}
+
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		t.Fatalf("Err() = %T, want *StatusError", err)
+	}
+	if statusErr.Message == "" {
+		t.Error("Err() returned a StatusError without the C status message")
+	}
+}
@@ -0,0 +1,168 @@
+/* *********************************************************************
+ * This Original Work is copyright of 51 Degrees Mobile Experts Limited.
+ * Copyright 2019 51 Degrees Mobile Experts Limited, 5 Charlotte Close,
+ * Caversham, Reading, Berkshire, United Kingdom RG4 7BY.
+ *
+ * This Original Work is licensed under the European Union Public Licence (EUPL)
+ * v.1.2 and is subject to its terms as set out below.
+ *
+ * If a copy of the EUPL was not distributed with this file, You can obtain
+ * one at https://opensource.org/licenses/EUPL-1.2.
+ *
+ * The 'Compatible Licences' set out in the Appendix to the EUPL (as may be
+ * amended by the European Commission) shall be deemed incompatible for
+ * the purposes of the Work and the provisions of the compatibility
+ * clause in Article 5 of the EUPL shall not apply.
+ *
+ * If using the Work as, or as part of, a network application, by
+ * including the attribution notice(s) required under Article 5 of the EUPL
+ * in the end user terms of the application under an appropriate heading,
+ * such notice(s) shall fulfill the requirements of that article.
+ * ********************************************************************* */
+package ipi_interop
+
+import "fmt"
+
+// PropertyTypeError is returned by the TypedValues accessors when the value
+// of a property cannot be represented as the requested Go type.
+type PropertyTypeError struct {
+	// Property is the name of the property which was requested
+	Property string
+	// Want is the Go type the caller asked for
+	Want string
+	// ValueType is the value type the data file declares for the property
+	ValueType PropertyValueType
+	// Value is the value which was found
+	Value interface{}
+}
+
+func (e *PropertyTypeError) Error() string {
+	return fmt.Sprintf(
+		"property '%s' of value type %d holds %T, not %s",
+		e.Property, e.ValueType, e.Value, e.Want)
+}
+
+// TypedValues wraps the Values returned by a lookup together with the value
+// type of each property, and provides typed accessors which report a missing
+// property or a mismatched type as an error rather than a zero value.
+type TypedValues struct {
+	values Values
+	types  map[string]PropertyValueType
+}
+
+// NewTypedValues returns the values wrapped with the property value types
+// which describe them. types should hold every property the lookup could
+// return, as from GetPropertyValueTypes.
+func NewTypedValues(values Values, types map[string]PropertyValueType) *TypedValues {
+	if values == nil {
+		values = Values{}
+	}
+	return &TypedValues{values: values, types: types}
+}
+
+// Values returns the underlying values.
+func (t *TypedValues) Values() Values {
+	return t.values
+}
+
+// Type returns the value type the data file declares for the property, and
+// false if the property is not known.
+func (t *TypedValues) Type(property string) (PropertyValueType, bool) {
+	valueType, ok := t.types[property]
+	return valueType, ok
+}
+
+// Weighted returns every value of the property with its weight. A property
+// which is not known returns a NoValueError with the reason
+// NoValueReasonInvalidProperty, and a known property without values returns
+// one with NoValueReasonNoResultForProperty.
+func (t *TypedValues) Weighted(property string) ([]*WeightedValue, error) {
+	if _, ok := t.types[property]; !ok {
+		return nil, &NoValueError{
+			Property: property,
+			Reason:   NoValueReasonInvalidProperty,
+		}
+	}
+	values := t.values[property]
+	if len(values) == 0 {
+		return nil, &NoValueError{
+			Property: property,
+			Reason:   NoValueReasonNoResultForProperty,
+		}
+	}
+	return values, nil
+}
+
+// String returns the first value of the property as a string.
+func (t *TypedValues) String(property string) (string, error) {
+	value, err := t.first(property)
+	if err != nil {
+		return "", err
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	return "", t.typeError(property, "string", value)
+}
+
+// Int returns the first value of the property as an int. Byte values are
+// widened.
+func (t *TypedValues) Int(property string) (int, error) {
+	value, err := t.first(property)
+	if err != nil {
+		return 0, err
+	}
+	switch i := value.(type) {
+	case int:
+		return i, nil
+	case byte:
+		return int(i), nil
+	}
+	return 0, t.typeError(property, "int", value)
+}
+
+// Float64 returns the first value of the property as a float64. Single
+// precision values are widened.
+func (t *TypedValues) Float64(property string) (float64, error) {
+	value, err := t.first(property)
+	if err != nil {
+		return 0, err
+	}
+	switch f := value.(type) {
+	case float64:
+		return f, nil
+	case float32:
+		return float64(f), nil
+	}
+	return 0, t.typeError(property, "float64", value)
+}
+
+// Bool returns the first value of the property as a bool.
+func (t *TypedValues) Bool(property string) (bool, error) {
+	value, err := t.first(property)
+	if err != nil {
+		return false, err
+	}
+	if b, ok := value.(bool); ok {
+		return b, nil
+	}
+	return false, t.typeError(property, "bool", value)
+}
+
+// first returns the first value of the property, ignoring its weight.
+func (t *TypedValues) first(property string) (interface{}, error) {
+	values, err := t.Weighted(property)
+	if err != nil {
+		return nil, err
+	}
+	return values[0].Value, nil
+}
+
+func (t *TypedValues) typeError(property, want string, value interface{}) error {
+	return &PropertyTypeError{
+		Property:  property,
+		Want:      want,
+		ValueType: t.types[property],
+		Value:     value,
+	}
+}
@@ -0,0 +1,217 @@
+/* *********************************************************************
+ * This Original Work is copyright of 51 Degrees Mobile Experts Limited.
+ * Copyright 2019 51 Degrees Mobile Experts Limited, 5 Charlotte Close,
+ * Caversham, Reading, Berkshire, United Kingdom RG4 7BY.
+ *
+ * This Original Work is licensed under the European Union Public Licence (EUPL)
+ * v.1.2 and is subject to its terms as set out below.
+ *
+ * If a copy of the EUPL was not distributed with this file, You can obtain
+ * one at https://opensource.org/licenses/EUPL-1.2.
+ *
+ * The 'Compatible Licences' set out in the Appendix to the EUPL (as may be
+ * amended by the European Commission) shall be deemed incompatible for
+ * the purposes of the Work and the provisions of the compatibility
+ * clause in Article 5 of the EUPL shall not apply.
+ *
+ * If using the Work as, or as part of, a network application, by
+ * including the attribution notice(s) required under Article 5 of the EUPL
+ * in the end user terms of the application under an appropriate heading,
+ * such notice(s) shall fulfill the requirements of that article.
+ * ********************************************************************* */
+package ipi_interop
+
+import (
+	"errors"
+	"testing"
+)
+
+func newTestTypedValues() *TypedValues {
+	values := Values{}
+	values.AppendWithWeight("Country", "United Kingdom", 1.0)
+	values.AppendWithWeight("AccuracyRadius", 1000, 1.0)
+	values.AppendWithWeight("Latitude", 51.5, 1.0)
+	values.AppendWithWeight("Area", float32(2.5), 1.0)
+	values.AppendWithWeight("IsProxy", true, 1.0)
+	values.AppendWithWeight("ConfidenceLevel", byte(3), 1.0)
+	values.AppendWithWeight("Mcc", "234", 0.75)
+	values.AppendWithWeight("Mcc", "235", 0.25)
+	values.InitProperty("RegisteredName")
+
+	return NewTypedValues(values, map[string]PropertyValueType{
+		"Country":         StringValueType,
+		"AccuracyRadius":  IntegerValueType,
+		"Latitude":        DoubleValueType,
+		"Area":            FloatValueType,
+		"IsProxy":         BooleanValueType,
+		"ConfidenceLevel": ByteValueType,
+		"Mcc":             WeightedStringValueType,
+		"RegisteredName":  StringValueType,
+	})
+}
+
+func TestTypedValues_Accessors(t *testing.T) {
+	typed := newTestTypedValues()
+
+	tests := []struct {
+		name     string
+		property string
+		get      func(string) (interface{}, error)
+		want     interface{}
+		wantErr  error
+	}{
+		{
+			name:     "string",
+			property: "Country",
+			get:      func(p string) (interface{}, error) { return typed.String(p) },
+			want:     "United Kingdom",
+		},
+		{
+			name:     "int",
+			property: "AccuracyRadius",
+			get:      func(p string) (interface{}, error) { return typed.Int(p) },
+			want:     1000,
+		},
+		{
+			name:     "byte widened",
+			property: "ConfidenceLevel",
+			get:      func(p string) (interface{}, error) { return typed.Int(p) },
+			want:     3,
+		},
+		{
+			name:     "double",
+			property: "Latitude",
+			get:      func(p string) (interface{}, error) { return typed.Float64(p) },
+			want:     51.5,
+		},
+		{
+			name:     "single precision widened",
+			property: "Area",
+			get:      func(p string) (interface{}, error) { return typed.Float64(p) },
+			want:     2.5,
+		},
+		{
+			name:     "bool",
+			property: "IsProxy",
+			get:      func(p string) (interface{}, error) { return typed.Bool(p) },
+			want:     true,
+		},
+		{
+			name:     "first weighted value",
+			property: "Mcc",
+			get:      func(p string) (interface{}, error) { return typed.String(p) },
+			want:     "234",
+		},
+		{
+			name:     "int zero value for missing property",
+			property: "Unknown",
+			get:      func(p string) (interface{}, error) { return typed.Int(p) },
+			want:     0,
+			wantErr:  ErrInvalidProperty,
+		},
+		{
+			name:     "property without values",
+			property: "RegisteredName",
+			get:      func(p string) (interface{}, error) { return typed.String(p) },
+			want:     "",
+			wantErr:  &NoValueError{Reason: NoValueReasonNoResultForProperty},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.get(tt.property)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+				}
+			} else if err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestTypedValues_WrongType(t *testing.T) {
+	typed := newTestTypedValues()
+
+	tests := []struct {
+		name      string
+		property  string
+		get       func(string) error
+		want      string
+		valueType PropertyValueType
+	}{
+		{
+			name:      "string as int",
+			property:  "Country",
+			get:       func(p string) error { _, err := typed.Int(p); return err },
+			want:      "int",
+			valueType: StringValueType,
+		},
+		{
+			name:      "int as string",
+			property:  "AccuracyRadius",
+			get:       func(p string) error { _, err := typed.String(p); return err },
+			want:      "string",
+			valueType: IntegerValueType,
+		},
+		{
+			name:      "bool as float64",
+			property:  "IsProxy",
+			get:       func(p string) error { _, err := typed.Float64(p); return err },
+			want:      "float64",
+			valueType: BooleanValueType,
+		},
+		{
+			name:      "double as bool",
+			property:  "Latitude",
+			get:       func(p string) error { _, err := typed.Bool(p); return err },
+			want:      "bool",
+			valueType: DoubleValueType,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var typeErr *PropertyTypeError
+			if err := tt.get(tt.property); !errors.As(err, &typeErr) {
+				t.Fatalf("Expected *PropertyTypeError, got %v", err)
+			}
+			if typeErr.Property != tt.property {
+				t.Errorf("Expected property %s, got %s", tt.property, typeErr.Property)
+			}
+			if typeErr.Want != tt.want {
+				t.Errorf("Expected want %s, got %s", tt.want, typeErr.Want)
+			}
+			if typeErr.ValueType != tt.valueType {
+				t.Errorf("Expected value type %d, got %d", tt.valueType, typeErr.ValueType)
+			}
+		})
+	}
+}
+
+func TestTypedValues_Weighted(t *testing.T) {
+	typed := newTestTypedValues()
+
+	values, err := typed.Weighted("Mcc")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(values) != 2 {
+		t.Fatalf("Expected 2 values, got %d", len(values))
+	}
+	if values[1].Value != "235" || values[1].Weight != 0.25 {
+		t.Errorf("Expected 235 with weight 0.25, got %v with weight %v", values[1].Value, values[1].Weight)
+	}
+
+	if valueType, ok := typed.Type("Mcc"); !ok || valueType != WeightedStringValueType {
+		t.Errorf("Expected WeightedStringValueType, got %d", valueType)
+	}
+	if _, ok := typed.Type("Unknown"); ok {
+		t.Error("Expected unknown property to have no type")
+	}
+}
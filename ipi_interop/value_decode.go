@@ -0,0 +1,238 @@
+/* *********************************************************************
+ * This Original Work is copyright of 51 Degrees Mobile Experts Limited.
+ * Copyright 2019 51 Degrees Mobile Experts Limited, 5 Charlotte Close,
+ * Caversham, Reading, Berkshire, United Kingdom RG4 7BY.
+ *
+ * This Original Work is licensed under the European Union Public Licence (EUPL)
+ * v.1.2 and is subject to its terms as set out below.
+ *
+ * If a copy of the EUPL was not distributed with this file, You can obtain
+ * one at https://opensource.org/licenses/EUPL-1.2.
+ *
+ * The 'Compatible Licences' set out in the Appendix to the EUPL (as may be
+ * amended by the European Commission) shall be deemed incompatible for
+ * the purposes of the Work and the provisions of the compatibility
+ * clause in Article 5 of the EUPL shall not apply.
+ *
+ * If using the Work as, or as part of, a network application, by
+ * including the attribution notice(s) required under Article 5 of the EUPL
+ * in the end user terms of the application under an appropriate heading,
+ * such notice(s) shall fulfill the requirements of that article.
+ * ********************************************************************* */
+package ipi_interop
+
+//#include <string.h>
+//#include "ip-intelligence-cxx.h"
+import "C"
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+// Coordinate is the decoded value of a property with the value type
+// CoordinateValueType.
+type Coordinate struct {
+	Lat float32
+	Lon float32
+}
+
+// String returns the coordinate in the "lat,lon" form the C library writes.
+func (c Coordinate) String() string {
+	return fmt.Sprintf("%g,%g", c.Lat, c.Lon)
+}
+
+// Geometry is the decoded value of a property with a Well Known Binary value
+// type. WKB holds the raw bytes from the data file and WKT the Well Known Text
+// the C library writes for them, which is also how the value prints.
+type Geometry struct {
+	WKB []byte
+	WKT string
+}
+
+// String returns the geometry as Well Known Text.
+func (g Geometry) String() string {
+	return g.WKT
+}
+
+// MarshalText returns the geometry as Well Known Text.
+func (g Geometry) MarshalText() ([]byte, error) {
+	return []byte(g.WKT), nil
+}
+
+// decodeValue converts a value read from the C values collection into the Go
+// type for the value type the data file declares for the property:
+//
+//   - IntegerValueType, WeightedIntValueType: int
+//   - DoubleValueType, WeightedDoubleValueType, DeclinationValueType,
+//     AzimuthValueType: float64
+//   - FloatValueType, WeightedSingleValueType: float32
+//   - BooleanValueType, WeightedBoolValueType: bool
+//   - ByteValueType, WeightedByteValueType: int, as the C layer has always
+//     provided them, so existing type assertions keep working
+//   - CoordinateValueType: Coordinate
+//   - IpAddressValueType, WeightedIpAddressValueType: netip.Addr
+//   - WkbValueType, WkbRValueType, WeightedWkbRValueType: Geometry
+//   - StringValueType, WeightedStringValueType, JavascriptValueType,
+//     ObjectValueType: string
+//
+// The C layer only produces integers, doubles, booleans, bytes and strings,
+// and writes every other type as a string, so those are parsed here. A value
+// which cannot be converted is returned unchanged rather than lost. Only the
+// WKT of a Geometry is known here, the caller adds the raw bytes.
+func decodeValue(valueType PropertyValueType, value interface{}) interface{} {
+	switch valueType {
+	case IntegerValueType, WeightedIntValueType:
+		switch v := value.(type) {
+		case int:
+			return v
+		case string:
+			if i, err := strconv.Atoi(v); err == nil {
+				return i
+			}
+		}
+	case DoubleValueType, WeightedDoubleValueType, DeclinationValueType, AzimuthValueType:
+		switch v := value.(type) {
+		case float64:
+			return v
+		case string:
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				return f
+			}
+		}
+	case FloatValueType, WeightedSingleValueType:
+		switch v := value.(type) {
+		case float32:
+			return v
+		case float64:
+			return float32(v)
+		case string:
+			if f, err := strconv.ParseFloat(v, 32); err == nil {
+				return float32(f)
+			}
+		}
+	case BooleanValueType, WeightedBoolValueType:
+		switch v := value.(type) {
+		case bool:
+			return v
+		case string:
+			if b, err := strconv.ParseBool(v); err == nil {
+				return b
+			}
+		}
+	case ByteValueType, WeightedByteValueType:
+		switch v := value.(type) {
+		case int:
+			return v
+		case byte:
+			return int(v)
+		case string:
+			if b, err := strconv.ParseUint(v, 10, 8); err == nil {
+				return int(b)
+			}
+		}
+	case CoordinateValueType:
+		if v, ok := value.(string); ok {
+			if c, ok := parseCoordinate(v); ok {
+				return c
+			}
+		}
+	case IpAddressValueType, WeightedIpAddressValueType:
+		if v, ok := value.(string); ok {
+			if addr, err := netip.ParseAddr(v); err == nil {
+				return addr
+			}
+		}
+	case WkbValueType, WkbRValueType, WeightedWkbRValueType:
+		switch v := value.(type) {
+		case Geometry:
+			return v
+		case string:
+			return Geometry{WKT: v}
+		}
+	}
+	return value
+}
+
+// parseCoordinate parses a coordinate written as "lat,lon".
+func parseCoordinate(s string) (Coordinate, bool) {
+	lat, lon, found := strings.Cut(s, ",")
+	if !found {
+		return Coordinate{}, false
+	}
+	latValue, err := strconv.ParseFloat(strings.TrimSpace(lat), 32)
+	if err != nil {
+		return Coordinate{}, false
+	}
+	lonValue, err := strconv.ParseFloat(strings.TrimSpace(lon), 32)
+	if err != nil {
+		return Coordinate{}, false
+	}
+	return Coordinate{Lat: float32(latValue), Lon: float32(lonValue)}, true
+}
+
+// wkbMatcher gives the geometries decoded from the values collection the raw
+// WKB bytes of the same values, which the collection only provides as text.
+// The values collection is populated by converting, in order, the items
+// ResultsIpiGetValues returns for each property, so the nth geometry of a
+// property is the nth raw value fetch returns for it. fetch is called at most
+// once per property.
+type wkbMatcher struct {
+	fetch func(requiredIndex int) [][]byte
+	raw   map[int][][]byte
+	next  map[int]int
+}
+
+func newWkbMatcher(fetch func(requiredIndex int) [][]byte) *wkbMatcher {
+	return &wkbMatcher{fetch: fetch, raw: make(map[int][][]byte), next: make(map[int]int)}
+}
+
+// match returns geometry, the next value of the property at requiredIndex,
+// with its raw bytes. It is returned with only its WKT if the raw values
+// could not be read.
+func (m *wkbMatcher) match(requiredIndex int, geometry Geometry) Geometry {
+	raw, fetched := m.raw[requiredIndex]
+	if !fetched {
+		raw = m.fetch(requiredIndex)
+		m.raw[requiredIndex] = raw
+	}
+	if i := m.next[requiredIndex]; i < len(raw) {
+		geometry.WKB = raw[i]
+	}
+	m.next[requiredIndex]++
+	return geometry
+}
+
+// getByteArrayValues returns a copy of the raw stored bytes of every value of
+// the property at the required index, in the order the values collection
+// lists them. It is used for WKB values, which the values collection only
+// provides as text. Calling it replaces any values previously fetched with
+// ResultsIpiGetValues.
+func (r *ResultsIpi) getByteArrayValues(requiredIndex C.int, exception *Exception) [][]byte {
+	exception.Clear()
+	first := C.ResultsIpiGetValues(r.CPtr, requiredIndex, exception.CPtr)
+	if !exception.IsOkay() || first == nil {
+		return nil
+	}
+
+	items := unsafe.Slice(r.CPtr.values.items, r.CPtr.values.count)
+	values := make([][]byte, 0, len(items))
+	for _, item := range items {
+		// The stored value is a packed fiftyoneDegreesVarLengthByteArray:
+		// an int16 size followed by the bytes themselves.
+		ptr := unsafe.Pointer(item.item.data.ptr)
+		if ptr == nil {
+			values = append(values, nil)
+			continue
+		}
+		size := *(*C.int16_t)(ptr)
+		if size <= 0 {
+			values = append(values, []byte{})
+			continue
+		}
+		values = append(values, C.GoBytes(unsafe.Add(ptr, 2), C.int(size)))
+	}
+	return values
+}
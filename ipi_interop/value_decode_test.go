@@ -0,0 +1,184 @@
+/* *********************************************************************
+ * This Original Work is copyright of 51 Degrees Mobile Experts Limited.
+ * Copyright 2019 51 Degrees Mobile Experts Limited, 5 Charlotte Close,
+ * Caversham, Reading, Berkshire, United Kingdom RG4 7BY.
+ *
+ * This Original Work is licensed under the European Union Public Licence (EUPL)
+ * v.1.2 and is subject to its terms as set out below.
+ *
+ * If a copy of the EUPL was not distributed with this file, You can obtain
+ * one at https://opensource.org/licenses/EUPL-1.2.
+ *
+ * The 'Compatible Licences' set out in the Appendix to the EUPL (as may be
+ * amended by the European Commission) shall be deemed incompatible for
+ * the purposes of the Work and the provisions of the compatibility
+ * clause in Article 5 of the EUPL shall not apply.
+ *
+ * If using the Work as, or as part of, a network application, by
+ * including the attribution notice(s) required under Article 5 of the EUPL
+ * in the end user terms of the application under an appropriate heading,
+ * such notice(s) shall fulfill the requirements of that article.
+ * ********************************************************************* */
+package ipi_interop
+
+import (
+	"fmt"
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeValue(t *testing.T) {
+	point := Geometry{
+		WKB: []byte{0, 0, 0, 0, 1, 64, 0, 0, 0, 0, 0, 0, 0, 64, 16, 0, 0, 0, 0, 0, 0},
+		WKT: "POINT(2 4)",
+	}
+
+	tests := []struct {
+		name      string
+		valueType PropertyValueType
+		value     interface{}
+		want      interface{}
+	}{
+		{name: "string", valueType: StringValueType, value: "London", want: "London"},
+		{name: "integer", valueType: IntegerValueType, value: 42, want: 42},
+		{name: "integer as string", valueType: IntegerValueType, value: "42", want: 42},
+		{name: "double", valueType: DoubleValueType, value: 51.5, want: 51.5},
+		{name: "boolean", valueType: BooleanValueType, value: true, want: true},
+		{name: "javascript", valueType: JavascriptValueType, value: "alert(1);", want: "alert(1);"},
+		{name: "single precision float", valueType: FloatValueType, value: 2.5, want: float32(2.5)},
+		{name: "single precision float from float32", valueType: FloatValueType, value: float32(2.5), want: float32(2.5)},
+		{name: "single byte", valueType: ByteValueType, value: 7, want: 7},
+		{name: "single byte from byte", valueType: ByteValueType, value: byte(7), want: 7},
+		{name: "coordinate", valueType: CoordinateValueType, value: "51.5,-0.125", want: Coordinate{Lat: 51.5, Lon: -0.125}},
+		{name: "coordinate invalid", valueType: CoordinateValueType, value: "51.5", want: "51.5"},
+		{name: "ipv4 address", valueType: IpAddressValueType, value: "192.168.0.1", want: netip.MustParseAddr("192.168.0.1")},
+		{name: "ipv6 address", valueType: IpAddressValueType, value: "2001:db8::1", want: netip.MustParseAddr("2001:db8::1")},
+		{name: "ip address invalid", valueType: IpAddressValueType, value: "unknown", want: "unknown"},
+		{name: "wkb", valueType: WkbValueType, value: "POINT(2 4)", want: Geometry{WKT: "POINT(2 4)"}},
+		{name: "wkb with bytes", valueType: WkbValueType, value: point, want: point},
+		{name: "object", valueType: ObjectValueType, value: "{}", want: "{}"},
+		{name: "declination", valueType: DeclinationValueType, value: "-45.5", want: -45.5},
+		{name: "azimuth", valueType: AzimuthValueType, value: "90", want: 90.0},
+		{name: "reduced wkb", valueType: WkbRValueType, value: "POINT(2 4)", want: Geometry{WKT: "POINT(2 4)"}},
+		{name: "weighted string", valueType: WeightedStringValueType, value: "234", want: "234"},
+		{name: "weighted int", valueType: WeightedIntValueType, value: "-3", want: -3},
+		{name: "weighted double", valueType: WeightedDoubleValueType, value: "0.25", want: 0.25},
+		{name: "weighted bool", valueType: WeightedBoolValueType, value: "True", want: true},
+		{name: "weighted single", valueType: WeightedSingleValueType, value: "1.5", want: float32(1.5)},
+		{name: "weighted byte", valueType: WeightedByteValueType, value: "255", want: 255},
+		{name: "weighted ip address", valueType: WeightedIpAddressValueType, value: "10.0.0.1", want: netip.MustParseAddr("10.0.0.1")},
+		{name: "weighted reduced wkb", valueType: WeightedWkbRValueType, value: "POINT(2 4)", want: Geometry{WKT: "POINT(2 4)"}},
+		{name: "unparsable value kept", valueType: WeightedIntValueType, value: "many", want: "many"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := decodeValue(tt.valueType, tt.value)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %#v (%T), got %#v (%T)", tt.want, tt.want, got, got)
+			}
+		})
+	}
+}
+
+func TestDecodeValue_AllValueTypes(t *testing.T) {
+	// Every value type must decode its canonical input into the Go type it is
+	// documented to produce, so a new type cannot be added without a decoder.
+	wantTypes := map[PropertyValueType]struct {
+		value interface{}
+		want  reflect.Type
+	}{
+		StringValueType:            {"a", reflect.TypeOf("")},
+		IntegerValueType:           {1, reflect.TypeOf(0)},
+		DoubleValueType:            {1.0, reflect.TypeOf(0.0)},
+		BooleanValueType:           {true, reflect.TypeOf(false)},
+		JavascriptValueType:        {"a", reflect.TypeOf("")},
+		FloatValueType:             {1.0, reflect.TypeOf(float32(0))},
+		ByteValueType:              {1, reflect.TypeOf(0)},
+		CoordinateValueType:        {"1,2", reflect.TypeOf(Coordinate{})},
+		IpAddressValueType:         {"1.2.3.4", reflect.TypeOf(netip.Addr{})},
+		WkbValueType:               {"POINT EMPTY", reflect.TypeOf(Geometry{})},
+		ObjectValueType:            {"a", reflect.TypeOf("")},
+		DeclinationValueType:       {"1", reflect.TypeOf(0.0)},
+		AzimuthValueType:           {"1", reflect.TypeOf(0.0)},
+		WkbRValueType:              {"POINT EMPTY", reflect.TypeOf(Geometry{})},
+		WeightedStringValueType:    {"a", reflect.TypeOf("")},
+		WeightedIntValueType:       {"1", reflect.TypeOf(0)},
+		WeightedDoubleValueType:    {"1", reflect.TypeOf(0.0)},
+		WeightedBoolValueType:      {"true", reflect.TypeOf(false)},
+		WeightedSingleValueType:    {"1", reflect.TypeOf(float32(0))},
+		WeightedByteValueType:      {"1", reflect.TypeOf(0)},
+		WeightedIpAddressValueType: {"1.2.3.4", reflect.TypeOf(netip.Addr{})},
+		WeightedWkbRValueType:      {"POINT EMPTY", reflect.TypeOf(Geometry{})},
+	}
+
+	for valueType := StringValueType; valueType <= WeightedWkbRValueType; valueType++ {
+		tt, ok := wantTypes[valueType]
+		if !ok {
+			t.Errorf("Value type %d has no decoding test", valueType)
+			continue
+		}
+		if got := reflect.TypeOf(decodeValue(valueType, tt.value)); got != tt.want {
+			t.Errorf("Value type %d: expected %v, got %v", valueType, tt.want, got)
+		}
+	}
+}
+
+func TestGeometry_Text(t *testing.T) {
+	g := Geometry{WKB: []byte{0, 0, 0, 0, 1}, WKT: "POINT EMPTY"}
+	if got := fmt.Sprintf("%v", g); got != "POINT EMPTY" {
+		t.Errorf("Expected POINT EMPTY, got %s", got)
+	}
+	text, err := g.MarshalText()
+	if err != nil || string(text) != "POINT EMPTY" {
+		t.Errorf("Expected POINT EMPTY, got %s, %v", text, err)
+	}
+}
+
+func TestCoordinate_String(t *testing.T) {
+	c := Coordinate{Lat: 51.5, Lon: -0.125}
+	if got := c.String(); got != "51.5,-0.125" {
+		t.Errorf("Expected 51.5,-0.125, got %s", got)
+	}
+}
+
+func TestWkbMatcher(t *testing.T) {
+	// The raw values of each property are fetched once, and given to its
+	// geometries in the order the values collection lists them, however the
+	// properties are interleaved.
+	raw := map[int][][]byte{
+		1: {{1}, {2}},
+		3: {{3}},
+	}
+	fetched := map[int]int{}
+	m := newWkbMatcher(func(requiredIndex int) [][]byte {
+		fetched[requiredIndex]++
+		return raw[requiredIndex]
+	})
+
+	tests := []struct {
+		index int
+		wkt   string
+		want  []byte
+	}{
+		{index: 1, wkt: "POINT(1 1)", want: []byte{1}},
+		{index: 3, wkt: "POINT(3 3)", want: []byte{3}},
+		{index: 1, wkt: "POINT(2 2)", want: []byte{2}},
+		// More geometries than raw values keep only their WKT.
+		{index: 3, wkt: "POINT(4 4)", want: nil},
+		{index: 5, wkt: "POINT(5 5)", want: nil},
+	}
+	for _, tt := range tests {
+		got := m.match(tt.index, Geometry{WKT: tt.wkt})
+		if got.WKT != tt.wkt || !reflect.DeepEqual(got.WKB, tt.want) {
+			t.Errorf("match(%d, %s) = %+v, want WKB %v", tt.index, tt.wkt, got, tt.want)
+		}
+	}
+
+	for index, n := range fetched {
+		if n != 1 {
+			t.Errorf("Raw values of property %d fetched %d times, want once", index, n)
+		}
+	}
+}
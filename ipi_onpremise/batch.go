@@ -0,0 +1,173 @@
+package ipi_onpremise
+
+import (
+	"context"
+	"runtime"
+	"sync"
+
+	"github.com/51Degrees/ip-intelligence-go/v4/ipi_interop"
+)
+
+// BatchResult is the outcome of a single lookup made by ProcessStream.
+// Index is the position of the IP address in the input stream, so callers can restore the input
+// order if they need to, as results are delivered in the order the lookups complete.
+type BatchResult struct {
+	Index     int
+	IpAddress string
+	Values    ipi_interop.Values
+	Err       error
+}
+
+// batchJob is a single IP address queued for a batch worker, together with its input position.
+type batchJob struct {
+	index     int
+	ipAddress string
+}
+
+// ProcessBatch processes the given IP addresses using a bounded pool of worker goroutines. Each
+// worker owns one reusable ResultsIpi, see ProcessWithResults, which it replaces once the data
+// file is reloaded.
+// The returned slices have the same length and order as ips: values[i] and errs[i] are the
+// outcome of the lookup of ips[i].
+func (e *Engine) ProcessBatch(ips []string) ([]ipi_interop.Values, []error) {
+	return e.ProcessBatchContext(context.Background(), ips)
+}
+
+// ProcessBatchContext is the same as ProcessBatch but honours the cancellation and deadline of ctx.
+// Lookups which have not started when ctx is done report ctx.Err() in their errs entry.
+func (e *Engine) ProcessBatchContext(ctx context.Context, ips []string) ([]ipi_interop.Values, []error) {
+	values := make([]ipi_interop.Values, len(ips))
+	errs := make([]error, len(ips))
+	if len(ips) == 0 {
+		return values, errs
+	}
+
+	jobs := make(chan batchJob)
+	go func() {
+		defer close(jobs)
+		for i, ip := range ips {
+			jobs <- batchJob{index: i, ipAddress: ip}
+		}
+	}()
+
+	// Every job writes to its own index, so the slices can be filled without locking.
+	e.runBatchWorkers(ctx, e.batchWorkerCount(len(ips)), jobs, func(r BatchResult) {
+		values[r.Index] = r.Values
+		errs[r.Index] = r.Err
+	})
+
+	return values, errs
+}
+
+// ProcessStream processes IP addresses read from ips until it is closed or ctx is done, using the
+// same bounded worker pool as ProcessBatch. Results are delivered on the returned channel in the
+// order the lookups complete, and the channel is closed once every lookup has been delivered.
+// The caller must either drain the returned channel or cancel ctx to release the workers.
+// After a reload, or once the engine is stopped, each worker lets go of the previous data set when
+// it starts its next lookup, so a stream left waiting for input holds up Shutdown until ips is
+// closed or ctx is cancelled.
+func (e *Engine) ProcessStream(ctx context.Context, ips <-chan string) <-chan BatchResult {
+	out := make(chan BatchResult)
+
+	jobs := make(chan batchJob)
+	go func() {
+		defer close(jobs)
+		index := 0
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ip, ok := <-ips:
+				if !ok {
+					return
+				}
+				select {
+				case jobs <- batchJob{index: index, ipAddress: ip}:
+				case <-ctx.Done():
+					return
+				}
+				index++
+			}
+		}
+	}()
+
+	go func() {
+		defer close(out)
+		e.runBatchWorkers(ctx, e.batchWorkerCount(0), jobs, func(r BatchResult) {
+			select {
+			case out <- r:
+			case <-ctx.Done():
+			}
+		})
+	}()
+
+	return out
+}
+
+// runBatchWorkers starts the given number of workers to process jobs and waits for all of them to
+// finish. emit is called from the worker goroutines, once per job.
+func (e *Engine) runBatchWorkers(ctx context.Context, workers int, jobs <-chan batchJob, emit func(BatchResult)) {
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go e.batchWorker(ctx, &wg, jobs, emit)
+	}
+	wg.Wait()
+}
+
+// batchWorker processes jobs until the jobs channel is closed, reusing a single ResultsIpi for
+// every lookup it makes until the data set is reloaded, see workerResults. If the engine has been
+// stopped, every job reports errNoManager.
+func (e *Engine) batchWorker(ctx context.Context, wg *sync.WaitGroup, jobs <-chan batchJob, emit func(BatchResult)) {
+	defer wg.Done()
+
+	var results *ipi_interop.ResultsIpi
+	defer func() { results.Free() }()
+
+	for job := range jobs {
+		var err error
+		if results, err = e.workerResults(results); err != nil {
+			emit(BatchResult{Index: job.index, IpAddress: job.ipAddress, Err: err})
+			continue
+		}
+		values, err := e.processString(ctx, LookupPathBatch, job.ipAddress, results)
+		emit(BatchResult{
+			Index:     job.index,
+			IpAddress: job.ipAddress,
+			Values:    values,
+			Err:       err,
+		})
+	}
+}
+
+// workerResults returns the results a batch worker makes its next lookup with: results themselves
+// if they were created from the current data set, or new results otherwise. The old results are
+// freed, so that a long-running stream neither keeps a replaced data set in memory, nor misses the
+// update, nor rebuilds the property caches for every lookup, and so that it does not hold up
+// Shutdown. It returns errNoManager, with nil results, if the engine has been stopped.
+func (e *Engine) workerResults(results *ipi_interop.ResultsIpi) (*ipi_interop.ResultsIpi, error) {
+	if results != nil {
+		if caches := e.properties.Load(); caches != nil && caches.dataSet == results.DataSetID() {
+			return results, nil
+		}
+		results.Free()
+	}
+	return e.TryNewResultsIpi()
+}
+
+// batchWorkerCount returns the number of workers to use for a batch of the given size. A size of
+// zero means the size is not known in advance, as with ProcessStream.
+func (e *Engine) batchWorkerCount(size int) int {
+	workers := e.batchWorkers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	// There is no benefit in running more workers than lookups can proceed concurrently.
+	if e.slots != nil && cap(e.slots) < workers {
+		workers = cap(e.slots)
+	}
+	if size > 0 && size < workers {
+		workers = size
+	}
+	return workers
+}
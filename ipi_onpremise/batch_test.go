@@ -0,0 +1,253 @@
+package ipi_onpremise
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/51Degrees/ip-intelligence-go/v4/ipi_interop"
+)
+
+func TestEngine_batchWorkerCount(t *testing.T) {
+	tests := []struct {
+		name     string
+		workers  int
+		slots    int
+		size     int
+		expected int
+	}{
+		{
+			name:     "defaults to number of CPUs",
+			size:     0,
+			expected: runtime.NumCPU(),
+		},
+		{
+			name:     "configured workers",
+			workers:  3,
+			size:     100,
+			expected: 3,
+		},
+		{
+			name:     "limited by batch size",
+			workers:  8,
+			size:     2,
+			expected: 2,
+		},
+		{
+			name:     "limited by collection concurrency",
+			workers:  8,
+			slots:    4,
+			size:     100,
+			expected: 4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := &Engine{batchWorkers: tt.workers}
+			if tt.slots > 0 {
+				engine.slots = make(chan struct{}, tt.slots)
+			}
+
+			if got := engine.batchWorkerCount(tt.size); got != tt.expected {
+				t.Errorf("Expected %d workers, got %d", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestEngine_ProcessBatch_Empty(t *testing.T) {
+	// No workers are started for an empty batch, so no data file is needed.
+	engine := &Engine{}
+
+	values, errs := engine.ProcessBatch(nil)
+	if len(values) != 0 || len(errs) != 0 {
+		t.Errorf("Expected empty results, got %d values and %d errors", len(values), len(errs))
+	}
+}
+
+func TestEngine_ProcessBatch_NoManager(t *testing.T) {
+	// A stopped engine has no manager, so every lookup reports it rather than the worker panicking.
+	engine := &Engine{batchWorkers: 2}
+
+	values, errs := engine.ProcessBatch([]string{"8.8.8.8", "1.1.1.1", "2001:4860:4860::8888"})
+	for i := range errs {
+		if values[i] != nil || !errors.Is(errs[i], errNoManager) {
+			t.Errorf("Expected %v for lookup %d, got %v and %v", errNoManager, i, values[i], errs[i])
+		}
+	}
+
+	ips := make(chan string, 2)
+	ips <- "8.8.8.8"
+	ips <- "1.1.1.1"
+	close(ips)
+	count := 0
+	for result := range engine.ProcessStream(context.Background(), ips) {
+		if !errors.Is(result.Err, errNoManager) {
+			t.Errorf("Expected %v for %s, got %v", errNoManager, result.IpAddress, result.Err)
+		}
+		count++
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 results, got %d", count)
+	}
+}
+
+func TestEngine_NewResultsIpi_NoManager(t *testing.T) {
+	engine := &Engine{}
+
+	results := engine.NewResultsIpi()
+	if results != nil {
+		t.Errorf("Expected no results, got %v", results)
+	}
+	// Nil results can be freed, and make the lookup report the engine is stopped.
+	results.Free()
+	if _, err := engine.ProcessWithResults("8.8.8.8", results); !errors.Is(err, errNoManager) {
+		t.Errorf("Expected %v, got %v", errNoManager, err)
+	}
+
+	if _, err := engine.TryNewResultsIpi(); !errors.Is(err, errNoManager) {
+		t.Errorf("Expected %v, got %v", errNoManager, err)
+	}
+}
+
+func TestEngine_ProcessStream_Reload(t *testing.T) {
+	data := readTestDataFile(t)
+	engine, err := New(WithDataBytes(data), WithBatchWorkers(1))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer engine.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ips := make(chan string)
+	out := engine.ProcessStream(ctx, ips)
+	lookup := func() {
+		t.Helper()
+		ips <- "8.8.8.8"
+		if result := <-out; result.Err != nil {
+			t.Fatalf("Expected no error, got %v", result.Err)
+		}
+	}
+
+	lookup()
+	freed := make(chan struct{})
+	engine.manager.Load().OnFree(func() { close(freed) })
+	if err := engine.ReloadFromMemory(data); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// The worker still holds results of the previous data set until its next lookup.
+	lookup()
+	select {
+	case <-freed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the stream to let go of the previous data set")
+	}
+	close(ips)
+}
+
+func TestEngine_Lookups_ConcurrentReload(t *testing.T) {
+	// Run with -race: lookups made while the data set is swapped must never read values through
+	// the property indexes of another data set, or from a data set which has been freed.
+	data := readTestDataFile(t)
+	filePath := filepath.Join(t.TempDir(), "data.ipi")
+	if err := os.WriteFile(filePath, data, 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", filePath, err)
+	}
+	engine, err := New(
+		WithDataFile(filePath),
+		WithAutoUpdate(false),
+		WithFileWatch(false),
+		WithTempDataDir(t.TempDir()),
+		WithBatchWorkers(2))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer engine.Stop()
+
+	ips := []string{"8.8.8.8", "1.1.1.1", "2001:4860:4860::8888", "185.28.167.78"}
+	expected := make(map[string]ipi_interop.Values, len(ips))
+	for _, ip := range ips {
+		if expected[ip], err = engine.Process(ip); err != nil {
+			t.Fatalf("Expected no error for %s, got %v", ip, err)
+		}
+	}
+	check := func(ip string, values ipi_interop.Values, err error) {
+		if err != nil {
+			t.Errorf("Expected no error for %s, got %v", ip, err)
+		} else if !reflect.DeepEqual(values, expected[ip]) {
+			t.Errorf("Values for %s differ from those before the reloads", ip)
+		}
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			ip := ips[i%len(ips)]
+			values, err := engine.Process(ip)
+			check(ip, values, err)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			values, errs := engine.ProcessBatch(ips)
+			for i, ip := range ips {
+				check(ip, values[i], errs[i])
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		in := make(chan string)
+		out := engine.ProcessStream(context.Background(), in)
+		go func() {
+			defer close(in)
+			for i := 0; ; i++ {
+				select {
+				case <-done:
+					return
+				case in <- ips[i%len(ips)]:
+				}
+			}
+		}()
+		for result := range out {
+			check(result.IpAddress, result.Values, result.Err)
+		}
+	}()
+
+	// Swap the data set under the lookups, from the file and from memory in turn.
+	for i := 0; i < 20; i++ {
+		if i%2 == 0 {
+			err = engine.processFileExternallyChanged(ReloadTriggerManual)
+		} else {
+			err = engine.ReloadFromMemory(data)
+		}
+		if err != nil {
+			t.Errorf("Reload %d failed: %v", i, err)
+		}
+	}
+	close(done)
+	wg.Wait()
+}
@@ -0,0 +1,143 @@
+package ipi_onpremise
+
+import (
+	"log/slog"
+	"time"
+)
+
+// ReloadTrigger is what caused the engine to (re)load its data file.
+type ReloadTrigger int
+
+const (
+	// ReloadTriggerInitial is the load of the data file when the engine is created.
+	ReloadTriggerInitial ReloadTrigger = iota
+	// ReloadTriggerFileWatcher is a reload after the file watcher saw the data file change. When the
+	// file watcher is enabled, data files downloaded by the file puller are reloaded this way too.
+	ReloadTriggerFileWatcher
+	// ReloadTriggerAutoUpdate is a reload of a data file downloaded by the file puller.
+	ReloadTriggerAutoUpdate
+	// ReloadTriggerManual is a reload requested by calling the engine, e.g. ReloadFromMemory.
+	ReloadTriggerManual
+
+	reloadTriggerCount = iota
+)
+
+// String returns the name of the trigger, e.g. "file watcher".
+func (t ReloadTrigger) String() string {
+	switch t {
+	case ReloadTriggerInitial:
+		return "initial"
+	case ReloadTriggerFileWatcher:
+		return "file watcher"
+	case ReloadTriggerAutoUpdate:
+		return "auto update"
+	case ReloadTriggerManual:
+		return "manual"
+	default:
+		return "unknown"
+	}
+}
+
+// ReloadEvent describes an attempt to (re)load the engine's data file, whether or not it succeeded.
+type ReloadEvent struct {
+	Trigger           ReloadTrigger // What caused the reload
+	FilePath          string        // Data file given with WithDataFile, empty when loaded from memory
+	PreviousPublished time.Time     // Published date of the data file in use before, zero if there was none
+	Published         time.Time     // Published date of the data file in use after, the previous one if Err is set
+	Duration          time.Duration // How long the reload took
+	Err               error         // Why the reload failed, nil if it succeeded
+}
+
+// reloadEventBuffer is the number of events a subscriber can fall behind by before events are
+// dropped for it.
+const reloadEventBuffer = 16
+
+// reload runs load, which (re)loads the data file, holding reloadMu, then reports the outcome to
+// the WithOnReload callbacks and the subscribers. The callbacks are called once reloadMu is
+// released, so they can use the engine, including reloading it.
+func (e *Engine) reload(trigger ReloadTrigger, filePath string, load func() error) error {
+	e.reloadMu.Lock()
+	if e.isStopped.Load() {
+		e.reloadMu.Unlock()
+		return errNoManager
+	}
+
+	event := ReloadEvent{
+		Trigger:           trigger,
+		FilePath:          filePath,
+		PreviousPublished: e.getPublishedDate(),
+	}
+	start := time.Now()
+	event.Err = load()
+	event.Duration = time.Since(start)
+	event.Published = event.PreviousPublished
+	if event.Err == nil {
+		event.Published = e.getPublishedDate()
+	}
+	e.reloadMu.Unlock()
+
+	e.logReload(event)
+	e.status.recordReload(start, event)
+	if e.metrics != nil {
+		e.metrics.ObserveReload(event)
+	}
+	e.emitReloadEvent(event)
+	return event.Err
+}
+
+// logReload logs the outcome of a load or reload of the data file.
+func (e *Engine) logReload(event ReloadEvent) {
+	source := []interface{}{"trigger", event.Trigger.String(), "path", event.FilePath}
+	if event.FilePath == "" {
+		source = []interface{}{"trigger", event.Trigger.String(), "source", "memory"}
+	}
+	if event.Err != nil {
+		e.log(slog.LevelError, "reload failed", append(source, "error", event.Err, "duration", event.Duration)...)
+		return
+	}
+	e.log(slog.LevelInfo, "data file loaded", append(source, "published", event.Published, "duration", event.Duration)...)
+}
+
+// Subscribe returns a channel which receives a ReloadEvent for every reload of the data file from
+// now on. The channel is buffered; if the subscriber falls further behind, events are dropped for it
+// rather than holding up reloads. The channel is closed when the engine is stopped.
+func (e *Engine) Subscribe() <-chan ReloadEvent {
+	ch := make(chan ReloadEvent, reloadEventBuffer)
+
+	e.eventsMu.Lock()
+	defer e.eventsMu.Unlock()
+	if e.isStopped.Load() {
+		close(ch)
+		return ch
+	}
+	e.subscribers = append(e.subscribers, ch)
+	return ch
+}
+
+// emitReloadEvent calls the WithOnReload callbacks with the event and sends it to the subscribers.
+// The callbacks run on the caller's goroutine, see WithOnReload for what they must not do.
+func (e *Engine) emitReloadEvent(event ReloadEvent) {
+	for _, onReload := range e.onReload {
+		onReload(event)
+	}
+
+	e.eventsMu.Lock()
+	defer e.eventsMu.Unlock()
+	for _, ch := range e.subscribers {
+		select {
+		case ch <- event:
+		default:
+			e.log(slog.LevelWarn, "dropped reload event for a subscriber which is not keeping up")
+		}
+	}
+}
+
+// closeSubscribers closes the channels returned by Subscribe, once the engine has been stopped.
+func (e *Engine) closeSubscribers() {
+	e.eventsMu.Lock()
+	defer e.eventsMu.Unlock()
+	for _, ch := range e.subscribers {
+		close(ch)
+	}
+	e.subscribers = nil
+}
@@ -0,0 +1,119 @@
+package ipi_onpremise
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	common_go "github.com/51Degrees/common-go/v4"
+)
+
+// log logs a structured event with the logger set with WithSlogLogger. Without one, the event is
+// formatted as a single "message key=value ..." line for the LogWriter of WithCustomLogger, or the
+// default logger, and debug events are dropped.
+func (e *Engine) log(level slog.Level, msg string, args ...interface{}) {
+	if e.loggingDisabled {
+		return
+	}
+	if e.slog != nil {
+		e.slog.Log(context.Background(), level, msg, args...)
+		return
+	}
+	if e.logger != nil {
+		slog.New(printfHandler{engine: e}).Log(context.Background(), level, msg, args...)
+	}
+}
+
+// logUpdater logs a message of the FileUpdater as a structured event, see updaterLogWriter. The
+// messages which end a pull attempt are given their own events.
+func (e *Engine) logUpdater(format string, v []interface{}) {
+	switch format {
+	case pullWrittenFormat:
+		e.log(slog.LevelInfo, "update downloaded", "path", e.GetDataFile(), "bytes", v[0])
+	case pullFailedFormat, pullFileInfoFormat, pullWriteFailedFormat:
+		err := pullError(format, v)
+		if errors.Is(err, common_go.ErrFileNotModified) {
+			e.log(slog.LevelDebug, "no newer data file to download")
+		} else {
+			e.log(slog.LevelError, "update download failed", "error", err)
+		}
+	default:
+		e.log(slog.LevelDebug, fmt.Sprintf(format, v...), "source", "file updater")
+	}
+}
+
+// printfHandler is the slog.Handler the engine logs through without WithSlogLogger. It writes each
+// record as a single line to the engine's LogWrapper.
+type printfHandler struct {
+	engine *Engine
+	// attrs are the attributes added with WithAttrs, already formatted
+	attrs string
+	// group is the prefix of the group opened with WithGroup, e.g. "reload."
+	group string
+}
+
+func (h printfHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= slog.LevelInfo
+}
+
+func (h printfHandler) Handle(_ context.Context, record slog.Record) error {
+	var line strings.Builder
+	line.WriteString(record.Message)
+	line.WriteString(h.attrs)
+	record.Attrs(func(attr slog.Attr) bool {
+		writeAttr(&line, h.group, attr)
+		return true
+	})
+	h.engine.logger.Printf("%s", line.String())
+	return nil
+}
+
+func (h printfHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var line strings.Builder
+	line.WriteString(h.attrs)
+	for _, attr := range attrs {
+		writeAttr(&line, h.group, attr)
+	}
+	h.attrs = line.String()
+	return h
+}
+
+func (h printfHandler) WithGroup(name string) slog.Handler {
+	if name != "" {
+		h.group += name + "."
+	}
+	return h
+}
+
+// writeAttr writes attr to line as " key=value", quoting values which contain spaces.
+func writeAttr(line *strings.Builder, group string, attr slog.Attr) {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return
+	}
+	if attr.Value.Kind() == slog.KindGroup {
+		if attr.Key != "" {
+			group += attr.Key + "."
+		}
+		for _, member := range attr.Value.Group() {
+			writeAttr(line, group, member)
+		}
+		return
+	}
+
+	var value string
+	switch attr.Value.Kind() {
+	case slog.KindTime:
+		value = attr.Value.Time().Format(time.RFC3339)
+	default:
+		value = attr.Value.String()
+	}
+	if value == "" || strings.ContainsAny(value, " \t\n\"=") {
+		value = strconv.Quote(value)
+	}
+	line.WriteString(" " + group + attr.Key + "=" + value)
+}
@@ -0,0 +1,135 @@
+package ipi_onpremise
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	common_go "github.com/51Degrees/common-go/v4"
+)
+
+// newSlogEngine returns an engine logging JSON records to the returned buffer.
+func newSlogEngine() (*Engine, *bytes.Buffer) {
+	var buf bytes.Buffer
+	engine := &Engine{FileUpdater: common_go.NewFileUpdater("")}
+	engine.slog = slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	engine.installLogWriter()
+	return engine, &buf
+}
+
+// records decodes the JSON records logged to buf.
+func records(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("Failed to decode %q: %v", line, err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestEngine_log_Reload(t *testing.T) {
+	engine, buf := newSlogEngine()
+
+	engine.reload(ReloadTriggerFileWatcher, "/data/51Degrees.ipi", func() error { return nil })
+	engine.reload(ReloadTriggerManual, "", func() error { return errors.New("corrupt data file") })
+
+	got := records(t, buf)
+	if len(got) != 2 {
+		t.Fatalf("Expected 2 records, got %v", got)
+	}
+	loaded, failed := got[0], got[1]
+	if loaded["msg"] != "data file loaded" || loaded["level"] != "INFO" || loaded["path"] != "/data/51Degrees.ipi" ||
+		loaded["trigger"] != "file watcher" || loaded["published"] == nil || loaded["duration"] == nil {
+		t.Errorf("Unexpected loaded record %v", loaded)
+	}
+	if failed["msg"] != "reload failed" || failed["level"] != "ERROR" || failed["error"] != "corrupt data file" ||
+		failed["source"] != "memory" {
+		t.Errorf("Unexpected failed record %v", failed)
+	}
+}
+
+// TestNew_FailedNotWarned checks that an engine which New failed to create is stopped without a
+// warning, as the failure is returned instead.
+func TestNew_FailedNotWarned(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	if _, err := New(WithSlogLogger(logger), WithDataBytes([]byte("not a data file"))); err == nil {
+		t.Fatal("Expected an error, got nil")
+	}
+	for _, record := range records(t, &buf) {
+		if record["level"] == "WARN" {
+			t.Errorf("Expected no warnings, got %v", record)
+		}
+	}
+}
+
+func TestEngine_log_Updater(t *testing.T) {
+	engine, buf := newSlogEngine()
+
+	engine.logger.Printf("Pulling data from %s", "https://example.com")
+	engine.logger.Printf(pullWrittenFormat, 10)
+	engine.logger.Printf(pullFailedFormat, errors.New("connection refused"))
+	engine.logger.Printf(pullFailedFormat, common_go.ErrFileNotModified)
+
+	got := records(t, buf)
+	if len(got) != 4 {
+		t.Fatalf("Expected 4 records, got %v", got)
+	}
+	if got[0]["msg"] != "Pulling data from https://example.com" || got[0]["level"] != "DEBUG" {
+		t.Errorf("Unexpected record %v", got[0])
+	}
+	if got[1]["msg"] != "update downloaded" || got[1]["bytes"] != float64(10) {
+		t.Errorf("Unexpected downloaded record %v", got[1])
+	}
+	if got[2]["msg"] != "update download failed" || got[2]["error"] != "connection refused" {
+		t.Errorf("Unexpected failed record %v", got[2])
+	}
+	if got[3]["level"] != "DEBUG" {
+		t.Errorf("Expected a file which was not modified to be logged at debug, got %v", got[3])
+	}
+
+	buf.Reset()
+	engine.loggingDisabled = true
+	engine.logger.Printf(pullWrittenFormat, 10)
+	engine.log(slog.LevelError, "reload failed")
+	if buf.Len() != 0 {
+		t.Errorf("Expected nothing to be logged once logging is disabled, got %q", buf.String())
+	}
+}
+
+func TestPrintfHandler(t *testing.T) {
+	logged := &testLogWriter{}
+	engine := &Engine{FileUpdater: common_go.NewFileUpdater(""), logWriter: logged}
+	engine.installLogWriter()
+	published := time.Date(2025, 3, 4, 0, 0, 0, 0, time.UTC)
+
+	engine.log(slog.LevelInfo, "data file loaded", "path", "/data/51Degrees.ipi", "published", published, "duration", 1500*time.Millisecond)
+	engine.log(slog.LevelError, "reload failed", "error", errors.New("bad header"), slog.Group("file", "name", "a b"))
+	engine.log(slog.LevelDebug, "dropped")
+
+	want := []string{
+		"data file loaded path=/data/51Degrees.ipi published=2025-03-04T00:00:00Z duration=1.5s",
+		`reload failed error="bad header" file.name="a b"`,
+	}
+	if strings.Join(logged.messages, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expected lines\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(logged.messages, "\n"))
+	}
+
+	logger := slog.New(printfHandler{engine: engine}).With("trigger", "manual").WithGroup("reload")
+	logger.Info("started", "attempt", 2)
+	if got := logged.messages[len(logged.messages)-1]; got != "started trigger=manual reload.attempt=2" {
+		t.Errorf("Unexpected line %q", got)
+	}
+}
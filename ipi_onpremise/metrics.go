@@ -0,0 +1,233 @@
+package ipi_onpremise
+
+import (
+	"errors"
+	"math"
+	"sync/atomic"
+	"time"
+
+	common_go "github.com/51Degrees/common-go/v4"
+	"github.com/51Degrees/ip-intelligence-go/v4/ipi_interop"
+)
+
+// Metrics receives the instrumentation of an engine, see WithMetrics. Its methods are called from
+// the goroutines making lookups and reloads, concurrently, so they must be safe for concurrent use
+// and should not block. NewExpvarMetrics and NewPrometheusMetrics provide ready made adapters.
+type Metrics interface {
+	// ObserveLookup records a lookup made through path, its outcome and how long it took, including
+	// any wait for a free slot in the C collections.
+	ObserveLookup(path LookupPath, outcome LookupOutcome, duration time.Duration)
+	// ObserveReload records a load or reload of the data file, whether or not it succeeded. The
+	// published date of a successful one gives the age of the live data.
+	ObserveReload(event ReloadEvent)
+	// ObserveDownload records an attempt of the file puller to download an updated data file. err is
+	// nil if a data file was downloaded, common_go.ErrFileNotModified if there was no newer one, and
+	// the reason the attempt failed otherwise.
+	ObserveDownload(err error)
+}
+
+// LookupPath is the API a lookup was made through.
+type LookupPath int
+
+const (
+	// LookupPathProcess is a lookup which creates its own results, such as Process, ProcessAddr or ProcessEvidence.
+	LookupPathProcess LookupPath = iota
+	// LookupPathProcessWithResults is a lookup which reuses the caller's results, such as ProcessWithResults.
+	LookupPathProcessWithResults
+	// LookupPathBatch is a lookup made by ProcessBatch or ProcessStream.
+	LookupPathBatch
+
+	lookupPathCount = iota
+)
+
+// String returns the name of the path, e.g. "process_with_results".
+func (p LookupPath) String() string {
+	switch p {
+	case LookupPathProcess:
+		return "process"
+	case LookupPathProcessWithResults:
+		return "process_with_results"
+	case LookupPathBatch:
+		return "batch"
+	default:
+		return "unknown"
+	}
+}
+
+// lookupPathFor returns the path of a lookup made with the given results, see ProcessWithResults.
+func lookupPathFor(results *ipi_interop.ResultsIpi) LookupPath {
+	if results == nil {
+		return LookupPathProcess
+	}
+	return LookupPathProcessWithResults
+}
+
+// LookupOutcome is how a lookup ended.
+type LookupOutcome int
+
+const (
+	// LookupOK is a lookup which returned values.
+	LookupOK LookupOutcome = iota
+	// LookupNoMatch is a lookup for which the data file had no result, see ipi_interop.ErrNoResults.
+	LookupNoMatch
+	// LookupError is a lookup which failed, for example for an invalid IP address.
+	LookupError
+
+	lookupOutcomeCount = iota
+)
+
+// String returns the name of the outcome, e.g. "no_match".
+func (o LookupOutcome) String() string {
+	switch o {
+	case LookupOK:
+		return "ok"
+	case LookupNoMatch:
+		return "no_match"
+	case LookupError:
+		return "error"
+	default:
+		return "unknown"
+	}
+}
+
+// lookupOutcomeOf returns the outcome of a lookup which returned err.
+func lookupOutcomeOf(err error) LookupOutcome {
+	if err == nil {
+		return LookupOK
+	}
+	if errors.Is(err, ipi_interop.ErrNoResults) {
+		return LookupNoMatch
+	}
+	return LookupError
+}
+
+// observeLookup reports a lookup which started at start to the engine's metrics, if any.
+func (e *Engine) observeLookup(path LookupPath, start time.Time, err error) {
+	if e.metrics != nil {
+		e.metrics.ObserveLookup(path, lookupOutcomeOf(err), time.Since(start))
+	}
+}
+
+var (
+	// lookupBuckets are the upper bounds, in seconds, of the lookup latency histograms. Lookups
+	// against a data file fully loaded into memory take microseconds.
+	lookupBuckets = []float64{0.00001, 0.000025, 0.00005, 0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.01, 0.1, 1}
+	// reloadBuckets are the upper bounds, in seconds, of the reload duration histogram.
+	reloadBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120}
+)
+
+// reloadResults are the label values of successful and failed reloads.
+var reloadResults = [2]string{"success", "failure"}
+
+// histogram is a cumulative-on-read histogram with fixed buckets, safe for concurrent use without locking.
+type histogram struct {
+	bounds []float64
+	counts []atomic.Uint64 // one per bound, plus one for larger values
+	sum    atomic.Uint64   // float64 bits of the sum of the observed values
+}
+
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{bounds: bounds, counts: make([]atomic.Uint64, len(bounds)+1)}
+}
+
+// observe records a value, in seconds.
+func (h *histogram) observe(value float64) {
+	i := 0
+	for i < len(h.bounds) && value > h.bounds[i] {
+		i++
+	}
+	h.counts[i].Add(1)
+	for {
+		old := h.sum.Load()
+		if h.sum.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+value)) {
+			return
+		}
+	}
+}
+
+// histogramSnapshot is a histogram at one moment. Buckets are cumulative, as in the Prometheus
+// text format: Buckets[i] is the number of values no greater than Bounds[i].
+type histogramSnapshot struct {
+	Bounds  []float64 `json:"bounds"`
+	Buckets []uint64  `json:"buckets"`
+	Count   uint64    `json:"count"`
+	Sum     float64   `json:"sum"`
+}
+
+func (h *histogram) snapshot() histogramSnapshot {
+	snapshot := histogramSnapshot{Bounds: h.bounds, Buckets: make([]uint64, len(h.bounds))}
+	var cumulative uint64
+	for i := range h.bounds {
+		cumulative += h.counts[i].Load()
+		snapshot.Buckets[i] = cumulative
+	}
+	snapshot.Count = cumulative + h.counts[len(h.bounds)].Load()
+	snapshot.Sum = math.Float64frombits(h.sum.Load())
+	return snapshot
+}
+
+// metricsRecorder implements Metrics by keeping the counters and histograms in memory, for the
+// adapters to publish. It does not lock, so lookups on different goroutines do not contend.
+type metricsRecorder struct {
+	lookups        [lookupPathCount][lookupOutcomeCount]atomic.Uint64
+	lookupDuration [lookupPathCount]*histogram
+
+	reloads        [reloadTriggerCount][len(reloadResults)]atomic.Uint64
+	reloadDuration *histogram
+
+	downloadAttempts    atomic.Uint64
+	downloadFailures    atomic.Uint64
+	downloadNotModified atomic.Uint64
+
+	// published is the published date of the live data file in Unix nanoseconds, zero if none is loaded
+	published atomic.Int64
+}
+
+func newMetricsRecorder() *metricsRecorder {
+	r := &metricsRecorder{reloadDuration: newHistogram(reloadBuckets)}
+	for i := range r.lookupDuration {
+		r.lookupDuration[i] = newHistogram(lookupBuckets)
+	}
+	return r
+}
+
+func (r *metricsRecorder) ObserveLookup(path LookupPath, outcome LookupOutcome, duration time.Duration) {
+	if path < 0 || path >= lookupPathCount || outcome < 0 || outcome >= lookupOutcomeCount {
+		return
+	}
+	r.lookups[path][outcome].Add(1)
+	r.lookupDuration[path].observe(duration.Seconds())
+}
+
+func (r *metricsRecorder) ObserveReload(event ReloadEvent) {
+	if event.Trigger < 0 || event.Trigger >= reloadTriggerCount {
+		return
+	}
+	if event.Err != nil {
+		r.reloads[event.Trigger][1].Add(1)
+	} else {
+		r.reloads[event.Trigger][0].Add(1)
+		if !event.Published.IsZero() {
+			r.published.Store(event.Published.UnixNano())
+		}
+	}
+	r.reloadDuration.observe(event.Duration.Seconds())
+}
+
+func (r *metricsRecorder) ObserveDownload(err error) {
+	r.downloadAttempts.Add(1)
+	if errors.Is(err, common_go.ErrFileNotModified) {
+		r.downloadNotModified.Add(1)
+	} else if err != nil {
+		r.downloadFailures.Add(1)
+	}
+}
+
+// dataFileAge returns how long ago the live data file was published, and false if none is loaded.
+func (r *metricsRecorder) dataFileAge(now time.Time) (time.Duration, bool) {
+	published := r.published.Load()
+	if published == 0 {
+		return 0, false
+	}
+	return now.Sub(time.Unix(0, published)), true
+}
@@ -0,0 +1,75 @@
+package ipi_onpremise
+
+import (
+	"expvar"
+	"strings"
+	"time"
+)
+
+// ExpvarMetrics is a Metrics which publishes the engine's instrumentation with the expvar package,
+// so it is served as JSON on /debug/vars alongside the runtime's own variables.
+type ExpvarMetrics struct {
+	*metricsRecorder
+}
+
+// NewExpvarMetrics returns an ExpvarMetrics published as the expvar variable name. Like
+// expvar.Publish, it panics if a variable with the name is already published, so create it once
+// per name and pass it to WithMetrics.
+func NewExpvarMetrics(name string) *ExpvarMetrics {
+	m := &ExpvarMetrics{metricsRecorder: newMetricsRecorder()}
+	expvar.Publish(name, expvar.Func(func() interface{} { return m.snapshot(time.Now()) }))
+	return m
+}
+
+// metricsSnapshot is the JSON form of the metrics published by ExpvarMetrics.
+type metricsSnapshot struct {
+	Lookups        map[string]map[string]uint64 `json:"lookups"`
+	LookupDuration map[string]histogramSnapshot `json:"lookupDurationSeconds"`
+
+	Reloads        map[string]map[string]uint64 `json:"reloads"`
+	ReloadDuration histogramSnapshot            `json:"reloadDurationSeconds"`
+
+	DownloadAttempts    uint64 `json:"downloadAttempts"`
+	DownloadFailures    uint64 `json:"downloadFailures"`
+	DownloadNotModified uint64 `json:"downloadNotModified"`
+
+	// DataFileAge is nil until a data file is loaded
+	DataFileAge *float64 `json:"dataFileAgeSeconds,omitempty"`
+}
+
+func (r *metricsRecorder) snapshot(now time.Time) metricsSnapshot {
+	snapshot := metricsSnapshot{
+		Lookups:             make(map[string]map[string]uint64, lookupPathCount),
+		LookupDuration:      make(map[string]histogramSnapshot, lookupPathCount),
+		Reloads:             make(map[string]map[string]uint64, reloadTriggerCount),
+		ReloadDuration:      r.reloadDuration.snapshot(),
+		DownloadAttempts:    r.downloadAttempts.Load(),
+		DownloadFailures:    r.downloadFailures.Load(),
+		DownloadNotModified: r.downloadNotModified.Load(),
+	}
+	for path := LookupPath(0); path < lookupPathCount; path++ {
+		outcomes := make(map[string]uint64, lookupOutcomeCount)
+		for outcome := LookupOutcome(0); outcome < lookupOutcomeCount; outcome++ {
+			outcomes[outcome.String()] = r.lookups[path][outcome].Load()
+		}
+		snapshot.Lookups[path.String()] = outcomes
+		snapshot.LookupDuration[path.String()] = r.lookupDuration[path].snapshot()
+	}
+	for trigger := ReloadTrigger(0); trigger < reloadTriggerCount; trigger++ {
+		results := make(map[string]uint64, len(reloadResults))
+		for i, result := range reloadResults {
+			results[result] = r.reloads[trigger][i].Load()
+		}
+		snapshot.Reloads[triggerLabel(trigger)] = results
+	}
+	if age, ok := r.dataFileAge(now); ok {
+		seconds := age.Seconds()
+		snapshot.DataFileAge = &seconds
+	}
+	return snapshot
+}
+
+// triggerLabel returns the name of a reload trigger as a metric label, e.g. "file_watcher".
+func triggerLabel(trigger ReloadTrigger) string {
+	return strings.ReplaceAll(trigger.String(), " ", "_")
+}
@@ -0,0 +1,113 @@
+package ipi_onpremise
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// PrometheusMetrics is a Metrics which writes the engine's instrumentation in the Prometheus text
+// exposition format. It is an http.Handler, so it can be served as the scrape endpoint directly,
+// or written along with other metrics with WriteTo.
+type PrometheusMetrics struct {
+	*metricsRecorder
+}
+
+// NewPrometheusMetrics returns a PrometheusMetrics to pass to WithMetrics.
+func NewPrometheusMetrics() *PrometheusMetrics {
+	return &PrometheusMetrics{metricsRecorder: newMetricsRecorder()}
+}
+
+// ServeHTTP serves the metrics in the Prometheus text format.
+func (m *PrometheusMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+// WriteTo writes the metrics to w in the Prometheus text format.
+func (m *PrometheusMetrics) WriteTo(w io.Writer) (int64, error) {
+	snapshot := m.snapshot(time.Now())
+	p := &promWriter{w: bufio.NewWriter(w)}
+
+	p.header("ipi_lookups_total", "counter", "Lookups by call path and outcome.")
+	for path := LookupPath(0); path < lookupPathCount; path++ {
+		for outcome := LookupOutcome(0); outcome < lookupOutcomeCount; outcome++ {
+			p.sample("ipi_lookups_total", fmt.Sprintf(`path=%q,outcome=%q`, path, outcome),
+				float64(snapshot.Lookups[path.String()][outcome.String()]))
+		}
+	}
+	p.header("ipi_lookup_duration_seconds", "histogram", "Lookup latency by call path.")
+	for path := LookupPath(0); path < lookupPathCount; path++ {
+		p.histogram("ipi_lookup_duration_seconds", fmt.Sprintf(`path=%q`, path), snapshot.LookupDuration[path.String()])
+	}
+
+	p.header("ipi_reloads_total", "counter", "Loads and reloads of the data file by trigger and result.")
+	for trigger := ReloadTrigger(0); trigger < reloadTriggerCount; trigger++ {
+		for _, result := range reloadResults {
+			p.sample("ipi_reloads_total", fmt.Sprintf(`trigger=%q,result=%q`, triggerLabel(trigger), result),
+				float64(snapshot.Reloads[triggerLabel(trigger)][result]))
+		}
+	}
+	p.header("ipi_reload_duration_seconds", "histogram", "Duration of loads and reloads of the data file.")
+	p.histogram("ipi_reload_duration_seconds", "", snapshot.ReloadDuration)
+
+	p.header("ipi_download_attempts_total", "counter", "Attempts of the file puller to download an updated data file.")
+	p.sample("ipi_download_attempts_total", "", float64(snapshot.DownloadAttempts))
+	p.header("ipi_download_failures_total", "counter", "Attempts of the file puller to download an updated data file which failed.")
+	p.sample("ipi_download_failures_total", "", float64(snapshot.DownloadFailures))
+	p.header("ipi_download_not_modified_total", "counter", "Attempts of the file puller which found no newer data file.")
+	p.sample("ipi_download_not_modified_total", "", float64(snapshot.DownloadNotModified))
+
+	if snapshot.DataFileAge != nil {
+		p.header("ipi_data_file_age_seconds", "gauge", "Time since the live data file was published.")
+		p.sample("ipi_data_file_age_seconds", "", *snapshot.DataFileAge)
+	}
+
+	if p.err == nil {
+		p.err = p.w.Flush()
+	}
+	return p.n, p.err
+}
+
+// promWriter writes lines of the Prometheus text format, keeping the first error.
+type promWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (p *promWriter) printf(format string, v ...interface{}) {
+	if p.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(p.w, format, v...)
+	p.n += int64(n)
+	p.err = err
+}
+
+func (p *promWriter) header(name, kind, help string) {
+	p.printf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func (p *promWriter) sample(name, labels string, value float64) {
+	if labels != "" {
+		name += "{" + labels + "}"
+	}
+	p.printf("%s %s\n", name, strconv.FormatFloat(value, 'g', -1, 64))
+}
+
+func (p *promWriter) histogram(name, labels string, h histogramSnapshot) {
+	separator := ""
+	if labels != "" {
+		separator = ","
+	}
+	for i, bound := range h.Bounds {
+		p.sample(name+"_bucket", fmt.Sprintf(`%s%sle="%s"`, labels, separator, strconv.FormatFloat(bound, 'g', -1, 64)), float64(h.Buckets[i]))
+	}
+	p.sample(name+"_bucket", labels+separator+`le="+Inf"`, float64(h.Count))
+	p.sample(name+"_sum", labels, h.Sum)
+	p.sample(name+"_count", labels, float64(h.Count))
+}
@@ -0,0 +1,203 @@
+package ipi_onpremise
+
+import (
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	common_go "github.com/51Degrees/common-go/v4"
+	"github.com/51Degrees/ip-intelligence-go/v4/ipi_interop"
+)
+
+// testMetrics records the lookups and downloads reported to it.
+type testMetrics struct {
+	lookups   []string
+	reloads   []ReloadEvent
+	downloads []error
+}
+
+func (m *testMetrics) ObserveLookup(path LookupPath, outcome LookupOutcome, duration time.Duration) {
+	m.lookups = append(m.lookups, path.String()+"/"+outcome.String())
+}
+
+func (m *testMetrics) ObserveReload(event ReloadEvent) {
+	m.reloads = append(m.reloads, event)
+}
+
+func (m *testMetrics) ObserveDownload(err error) {
+	m.downloads = append(m.downloads, err)
+}
+
+func TestLookupOutcomeOf(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want LookupOutcome
+	}{
+		{name: "ok", want: LookupOK},
+		{name: "no results", err: &ipi_interop.NoValueError{Reason: ipi_interop.NoValueReasonNoResults}, want: LookupNoMatch},
+		{name: "wrapped no results", err: fmt.Errorf("lookup: %w", ipi_interop.ErrNoResults), want: LookupNoMatch},
+		{name: "null profile", err: ipi_interop.ErrNullProfile, want: LookupError},
+		{name: "error", err: errInvalidAddr, want: LookupError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lookupOutcomeOf(tt.err); got != tt.want {
+				t.Errorf("lookupOutcomeOf() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEngine_Metrics_Lookups(t *testing.T) {
+	metrics := &testMetrics{}
+	engine := &Engine{metrics: metrics}
+
+	engine.Process("192.168.0.1")
+	engine.ProcessIP(nil)
+	engine.ProcessEvidenceWithResults(nil, &ipi_interop.ResultsIpi{})
+
+	want := []string{"process/error", "process/error", "process_with_results/error"}
+	if strings.Join(metrics.lookups, " ") != strings.Join(want, " ") {
+		t.Errorf("Expected lookups %v, got %v", want, metrics.lookups)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	h := newHistogram([]float64{1, 2})
+	for _, value := range []float64{0.5, 1, 1.5, 3} {
+		h.observe(value)
+	}
+
+	snapshot := h.snapshot()
+	if snapshot.Buckets[0] != 2 || snapshot.Buckets[1] != 3 || snapshot.Count != 4 {
+		t.Errorf("Expected cumulative buckets [2 3] of 4, got %v of %d", snapshot.Buckets, snapshot.Count)
+	}
+	if snapshot.Sum != 6 {
+		t.Errorf("Expected sum 6, got %v", snapshot.Sum)
+	}
+}
+
+func TestUpdaterLogWriter_Downloads(t *testing.T) {
+	metrics := &testMetrics{}
+	logged := &testLogWriter{}
+	engine := &Engine{FileUpdater: common_go.NewFileUpdater(""), metrics: metrics, logWriter: logged}
+	engine.installLogWriter()
+	pullErr := errors.New("connection refused")
+
+	engine.logger.Printf("Pulling data from %s", "https://example.com")
+	engine.logger.Printf(pullWrittenFormat, 10)
+	engine.logger.Printf(pullFailedFormat, common_go.ErrFileNotModified)
+	engine.logger.Printf(pullFailedFormat, pullErr)
+	engine.logger.Printf(pullWriteFailedFormat, "disk full")
+
+	if len(metrics.downloads) != 4 {
+		t.Fatalf("Expected 4 downloads, got %v", metrics.downloads)
+	}
+	if metrics.downloads[0] != nil || !errors.Is(metrics.downloads[1], common_go.ErrFileNotModified) ||
+		!errors.Is(metrics.downloads[2], pullErr) || metrics.downloads[3] == nil {
+		t.Errorf("Unexpected downloads %v", metrics.downloads)
+	}
+	if len(logged.messages) != 5 {
+		t.Errorf("Expected every message to be logged, got %v", logged.messages)
+	}
+
+	engine.loggingDisabled = true
+	engine.logger.Printf(pullWrittenFormat, 10)
+	if len(logged.messages) != 5 || len(metrics.downloads) != 5 {
+		t.Errorf("Expected downloads to be observed without logging, got %d messages and %d downloads", len(logged.messages), len(metrics.downloads))
+	}
+}
+
+// testLogWriter keeps the messages logged to it.
+type testLogWriter struct {
+	messages []string
+}
+
+func (w *testLogWriter) Printf(format string, v ...interface{}) {
+	w.messages = append(w.messages, fmt.Sprintf(format, v...))
+}
+
+// observeAll reports one of each kind of observation to metrics.
+func observeAll(metrics Metrics, published time.Time) {
+	metrics.ObserveLookup(LookupPathProcess, LookupOK, 20*time.Microsecond)
+	metrics.ObserveLookup(LookupPathBatch, LookupNoMatch, 2*time.Second)
+	metrics.ObserveReload(ReloadEvent{Trigger: ReloadTriggerFileWatcher, Published: published, Duration: time.Second})
+	metrics.ObserveReload(ReloadEvent{Trigger: ReloadTriggerAutoUpdate, Err: errors.New("corrupt"), Duration: time.Second})
+	metrics.ObserveDownload(nil)
+	metrics.ObserveDownload(common_go.ErrFileNotModified)
+	metrics.ObserveDownload(errors.New("timeout"))
+}
+
+func TestPrometheusMetrics(t *testing.T) {
+	metrics := NewPrometheusMetrics()
+
+	recorder := httptest.NewRecorder()
+	metrics.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	if strings.Contains(recorder.Body.String(), "ipi_data_file_age_seconds") {
+		t.Error("Expected no data file age before a data file is loaded")
+	}
+
+	observeAll(metrics, time.Now().Add(-time.Hour))
+
+	var out strings.Builder
+	if _, err := metrics.WriteTo(&out); err != nil {
+		t.Fatalf("WriteTo() error = %v", err)
+	}
+	for _, line := range []string{
+		"# TYPE ipi_lookups_total counter",
+		`ipi_lookups_total{path="process",outcome="ok"} 1`,
+		`ipi_lookups_total{path="batch",outcome="no_match"} 1`,
+		`ipi_lookups_total{path="process_with_results",outcome="error"} 0`,
+		"# TYPE ipi_lookup_duration_seconds histogram",
+		`ipi_lookup_duration_seconds_bucket{path="process",le="2.5e-05"} 1`,
+		`ipi_lookup_duration_seconds_bucket{path="batch",le="1"} 0`,
+		`ipi_lookup_duration_seconds_bucket{path="batch",le="+Inf"} 1`,
+		`ipi_lookup_duration_seconds_count{path="batch"} 1`,
+		`ipi_reloads_total{trigger="file_watcher",result="success"} 1`,
+		`ipi_reloads_total{trigger="auto_update",result="failure"} 1`,
+		`ipi_reload_duration_seconds_bucket{le="1"} 2`,
+		"ipi_reload_duration_seconds_sum 2",
+		"ipi_download_attempts_total 3",
+		"ipi_download_failures_total 1",
+		"ipi_download_not_modified_total 1",
+		"# TYPE ipi_data_file_age_seconds gauge",
+	} {
+		if !strings.Contains(out.String(), line+"\n") {
+			t.Errorf("Expected line %q in:\n%s", line, out.String())
+		}
+	}
+}
+
+// expvarTests numbers the expvar variables published by tests, as a name can only be published once.
+var expvarTests atomic.Int32
+
+func TestExpvarMetrics(t *testing.T) {
+	name := fmt.Sprintf("ipi_onpremise_test_%d", expvarTests.Add(1))
+	metrics := NewExpvarMetrics(name)
+	observeAll(metrics, time.Now().Add(-time.Hour))
+
+	var snapshot metricsSnapshot
+	if err := json.Unmarshal([]byte(expvar.Get(name).String()), &snapshot); err != nil {
+		t.Fatalf("Failed to decode published metrics: %v", err)
+	}
+	if snapshot.Lookups["process"]["ok"] != 1 || snapshot.Lookups["batch"]["no_match"] != 1 {
+		t.Errorf("Unexpected lookups %v", snapshot.Lookups)
+	}
+	if snapshot.Reloads["file_watcher"]["success"] != 1 || snapshot.Reloads["auto_update"]["failure"] != 1 {
+		t.Errorf("Unexpected reloads %v", snapshot.Reloads)
+	}
+	if snapshot.DownloadAttempts != 3 || snapshot.DownloadFailures != 1 {
+		t.Errorf("Expected 3 download attempts and 1 failure, got %d and %d", snapshot.DownloadAttempts, snapshot.DownloadFailures)
+	}
+	if snapshot.DataFileAge == nil || *snapshot.DataFileAge < 3600 {
+		t.Errorf("Expected a data file age of at least an hour, got %v", snapshot.DataFileAge)
+	}
+}
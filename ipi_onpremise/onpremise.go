@@ -1,12 +1,18 @@
 package ipi_onpremise
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
 	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	common_go "github.com/51Degrees/common-go/v4"
@@ -23,9 +29,15 @@ type Engine struct {
 	*common_go.FileUpdater
 	logger *common_go.LogWrapper
 
-	manager *ipi_interop.ResourceManager
+	// manager is the manager of the current data set. It is swapped atomically on reload; lookups
+	// take a reference to it with acquireManager, so a replaced manager is only freed once the
+	// last lookup, or ResultsIpi, using it has finished.
+	manager atomic.Pointer[ipi_interop.ResourceManager]
 	config  *ipi_interop.ConfigIpi
 
+	// reloadMu serialises reloads and guards dataFileLastUsedByManager
+	reloadMu sync.Mutex
+
 	stopCh           chan *sync.WaitGroup
 	reloadFileEvents chan struct{}
 
@@ -33,33 +45,111 @@ type Engine struct {
 	dataFileLastUsedByManager string
 	licenseKey                string
 
+	// data is the data file contents given by WithDataBytes, WithDataReader or WithDataFS. It is
+	// released once loaded, as the C layer keeps its own copy.
+	data []byte
+	// dataSize is the size of the data file last loaded from memory
+	dataSize atomic.Int64
+
 	maxRetries int
 
-	isStopped bool
+	batchWorkers int
+
+	// memoryBudget is the most memory, in bytes, the live and a new data set together may be
+	// estimated to need before the new one is loaded. Zero means no budget.
+	memoryBudget uint64
+	// liveSize is the estimated memory needed by the live data set, zero without a memory budget
+	liveSize atomic.Uint64
+
+	isStopped atomic.Bool
+
+	// slots bounds the number of concurrent lookups to the concurrency the C
+	// collections were configured for. nil when the collections are fully
+	// loaded into memory and do not limit concurrent access.
+	slots chan struct{}
+
+	managerProperties []string
+
+	// properties holds the property caches of the current data set. They are rebuilt after every
+	// reload, as a newer data file may order its properties differently, and swapped atomically.
+	properties atomic.Pointer[propertyCaches]
+
+	// onReload are the callbacks given by WithOnReload, called after every reload
+	onReload []func(ReloadEvent)
+	// eventsMu guards subscribers
+	eventsMu sync.Mutex
+	// subscribers are the channels returned by Subscribe, closed when the engine is stopped
+	subscribers []chan ReloadEvent
+
+	// shutdownOnce runs stop once, however many times Stop and Shutdown are called
+	shutdownOnce sync.Once
+	// stopped is closed once the file puller and watcher have stopped and the manager is released
+	stopped chan struct{}
+	// freed is closed once the manager has been freed and the temp copies of the data file removed
+	freed chan struct{}
+
+	// goldenIPs are checked against every data file before it is used, see WithGoldenIPs
+	goldenIPs []GoldenIP
+
+	// retainedVersions is the number of previous data files to keep, see WithRetainedVersions
+	retainedVersions int
+	// versionsMu guards versions, live and versionSeq
+	versionsMu sync.Mutex
+	// versions are the retained data files, least recently loaded first, so the live one is last
+	versions []retainedVersion
+	// live is the version of the live data file, once versions are retained
+	live retainedVersion
+	// versionSeq is the seq of the last data file retained
+	versionSeq uint64
+
+	// status tracks what Status reports beyond what the FileUpdater exposes
+	status engineStatus
+	// randomizationMs is the randomization set with WithRandomization, which the FileUpdater does not expose
+	randomizationMs int
+
+	// metrics receives the engine's instrumentation, nil if WithMetrics was not given
+	metrics Metrics
+	// logWriter is the writer set with WithCustomLogger, nil for the FileUpdater's default
+	logWriter common_go.LogWriter
+	// loggingDisabled is set by WithLogging(false)
+	loggingDisabled bool
+	// slog is the logger set with WithSlogLogger, nil to log through logger
+	slog *slog.Logger
+}
+
+// propertyCaches are the bidirectional property name↔index caches, and the declared value types,
+// of one data set. They are read-only once built.
+type propertyCaches struct {
+	// dataSet identifies the data set the caches were built from, see ResultsIpi.DataSetID
+	dataSet uintptr
+	// results pins the data set, so its ID cannot be reused by a later data set while the
+	// caches are current. nil for caches built for a single lookup.
+	results *ipi_interop.ResultsIpi
 
-	managerProperties  []string
 	propertyIndexCache map[string]int // name → index mapping
-	propertyNameCache  map[int]string // index → name mapping (readonly after init)
+	propertyNameCache  map[int]string // index → name mapping
 	propertyIndexes    []int
+	propertyTypes      map[string]ipi_interop.PropertyValueType // name → declared value type
 }
 
 const (
 	defaultDataFileUrl = "" // TODO: set default file path url (when it will be available)
 )
 
-// availablePropertyNamesProvider is the function used to enumerate all
-// property names from a loaded dataset. It is a package-level variable so that
-// tests can inject a mock without requiring a real ResourceManager or CGO.
-var availablePropertyNamesProvider = ipi_interop.GetAvailablePropertyNames
-
-// resultsPropertyIndexer is the subset of ResultsIpi needed to resolve a
-// property name to its required-property index in the current dataset.
+// resultsPropertyIndexer is the subset of ResultsIpi needed to build the
+// property caches of the dataset the results were created from. It is an
+// interface so that tests can inject a mock without requiring a real
+// ResourceManager or CGO.
 type resultsPropertyIndexer interface {
+	DataSetID() uintptr
 	GetPropertyIndexByName(string) int
+	AvailablePropertyNames() []string
+	PropertyValueTypes() map[string]ipi_interop.PropertyValueType
 }
 
 // New creates an instance of the on-premise IP intelligence engine.  WithDataFile must be provided
-// to specify the path to the data file, otherwise initialization will fail
+// to specify the path to the data file, or one of WithDataBytes, WithDataReader or WithDataFS to
+// provide its contents, otherwise initialization will fail
 func New(opts ...EngineOptions) (*Engine, error) {
 	fileUpdater := common_go.NewFileUpdater(defaultDataFileUrl)
 	logger := fileUpdater.GetLogger()
@@ -68,12 +158,11 @@ func New(opts ...EngineOptions) (*Engine, error) {
 		FileUpdater: fileUpdater,
 		logger:      logger,
 
-		config:             nil,
-		stopCh:             make(chan *sync.WaitGroup),
-		reloadFileEvents:   make(chan struct{}),
-		managerProperties:  nil, // nil means "all properties"
-		propertyIndexCache: make(map[string]int),
-		propertyNameCache:  make(map[int]string),
+		config:            nil,
+		stopCh:            make(chan *sync.WaitGroup),
+		reloadFileEvents:  make(chan struct{}),
+		managerProperties: nil,            // nil means "all properties"
+		randomizationMs:   10 * 60 * 1000, // the FileUpdater's default
 	}
 
 	for _, opt := range opts {
@@ -83,30 +172,50 @@ func New(opts ...EngineOptions) (*Engine, error) {
 			return nil, err
 		}
 	}
+	engine.installLogWriter()
 
-	if !engine.IsDataFileProvided() {
+	if engine.data != nil {
+		if engine.IsDataFileProvided() {
+			engine.Stop()
+			return nil, errDataFileAndInBytes
+		}
+		// There is no file on disk to watch, copy or replace with a downloaded one.
+		engine.SetIsFileWatcherEnabled(false)
+		engine.SetIsCreateTempDataCopyEnabled(false)
+		engine.SetIsAutoUpdateEnabled(false)
+	} else if !engine.IsDataFileProvided() {
+		engine.Stop()
 		return nil, common_go.ErrNoDataFileProvided
 	}
 
+	if engine.retainedVersions > 0 && !engine.IsCreateTempDataCopyEnabled() {
+		engine.Stop()
+		return nil, errRetainedVersionsNeedTempCopy
+	}
+
 	if err := engine.InitCreateTempDataCopy(); err != nil {
+		engine.Stop()
 		return nil, err
 	}
+	// The initial load also pre-computes the property indexes of the data set.
 	err := engine.run()
 	if err != nil {
 		engine.Stop()
 		return nil, err
 	}
 
-	// Pre-compute property indexes using a temporary results object
-	engine.initPropertyIndexes()
+	engine.initSlots()
 
 	// if file watcher is enabled, start the watcher
 	if engine.IsFileWatcherEnabled() {
+		// The file puller, and the manager, are already running, so have to be stopped.
 		if err := engine.InitFileWatcher(engine.logger, engine.stopCh); err != nil {
+			engine.Stop()
 			return nil, err
 		}
 
 		if err := engine.Watch(engine.handleFileExternallyChanged); err != nil {
+			engine.Stop()
 			return nil, err
 		}
 
@@ -119,11 +228,11 @@ func New(opts ...EngineOptions) (*Engine, error) {
 
 // handleFileExternallyChanged handles the logic for processing a file that has been altered externally to ensure consistency.
 func (e *Engine) handleFileExternallyChanged() {
-	if err := e.processFileExternallyChanged(); err != nil {
-		e.logger.Printf("failed to handle file externally changed: %v", err)
-	}
+	// A failed reload is logged and reported by reload.
+	_ = e.processFileExternallyChanged(ReloadTriggerFileWatcher)
 
 	e.IncreaseFileExternallyChangedCount()
+	e.status.recordExternalChange()
 }
 
 // run starts the engine
@@ -132,7 +241,13 @@ func (e *Engine) run() error {
 
 	go e.reloadFileEvent()
 
-	if err := e.processFileExternallyChanged(); err != nil {
+	if e.data != nil {
+		data := e.data
+		e.data = nil
+		if err := e.reload(ReloadTriggerInitial, "", func() error { return e.reloadFromMemory(data) }); err != nil {
+			return err
+		}
+	} else if err := e.processFileExternallyChanged(ReloadTriggerInitial); err != nil {
 		return err
 	}
 
@@ -142,6 +257,7 @@ func (e *Engine) run() error {
 
 	if e.IsAutoUpdateEnabled() {
 		e.SetFilePullerStarted(true)
+		e.status.recordFilePullerStarted(time.Now())
 		go e.ScheduleFilePulling(e.stopCh, e.reloadFileEvents)
 	}
 
@@ -149,8 +265,59 @@ func (e *Engine) run() error {
 }
 
 // Stop has to be called to free all the resources of the engine
-// before the instance goes out of scope
+// before the instance goes out of scope. It stops the file puller and watcher and releases the
+// manager, whose native resources are freed once the lookups still in flight have finished; see
+// Shutdown to wait for them. Calling Stop more than once, on an engine which is already shut down,
+// or on the nil engine returned by a failed New, has no further effect.
 func (e *Engine) Stop() {
+	_ = e.shutdown(context.Background(), false)
+}
+
+// Shutdown stops the engine like Stop, then waits for the lookups still in flight, and any
+// ResultsIpi still held, to finish before the manager and the temp copies of the data file are
+// freed. If ctx is done first, ctx.Err() is returned and whatever is left of the shutdown carries on
+// in the background. Like Stop, it is safe to call more than once.
+func (e *Engine) Shutdown(ctx context.Context) error {
+	return e.shutdown(ctx, true)
+}
+
+// shutdown starts stopping the engine, once however many times it is called, and waits for the
+// file puller and watcher to stop and, if drain is set, for the manager to be freed.
+func (e *Engine) shutdown(ctx context.Context, drain bool) error {
+	if e == nil {
+		return nil
+	}
+
+	e.shutdownOnce.Do(func() {
+		e.stopped = make(chan struct{})
+		e.freed = make(chan struct{})
+		go e.stop()
+	})
+
+	select {
+	case <-e.stopped:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if !drain {
+		return nil
+	}
+	select {
+	case <-e.freed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// stop stops the file puller and watcher and releases the manager, closing stopped once done and
+// freed once the manager has been freed. It is only run once, see shutdown.
+func (e *Engine) stop() {
+	defer close(e.stopped)
+
+	// No reload can start from here on.
+	e.isStopped.Store(true)
+
 	num := 0
 	if e.IsAutoUpdateEnabled() && e.IsFilePullerStarted() {
 		num++ // file puller is enabled and started
@@ -169,19 +336,31 @@ func (e *Engine) Stop() {
 		wg.Wait()
 	}
 
-	e.isStopped = true
 	close(e.stopCh)
 	close(e.reloadFileEvents)
+	e.closeSubscribers()
 
-	if e.manager != nil {
-		e.manager.Free()
-	} else {
-		e.logger.Printf("stopping engine, manager is nil")
-	}
+	// Wait for any reload in progress to finish before releasing the manager.
+	e.reloadMu.Lock()
+	defer e.reloadMu.Unlock()
 
-	if e.IsCreateTempDataCopyEnabled() {
-		dir := filepath.Dir(e.dataFileLastUsedByManager)
-		os.RemoveAll(dir)
+	e.storePropertyCaches(nil)
+	removeTempDir := func() {
+		if e.IsCreateTempDataCopyEnabled() && e.dataFileLastUsedByManager != "" {
+			os.RemoveAll(filepath.Dir(e.dataFileLastUsedByManager))
+		}
+		close(e.freed)
+	}
+	if manager := e.manager.Swap(nil); manager != nil {
+		// The native resources, and the temp copy of the data file they use, are freed once
+		// lookups still in flight have finished.
+		manager.OnFree(removeTempDir)
+		manager.Free()
+	} else {
+		// This is also how New cleans up when it fails to load the data file, which it has already
+		// reported, so it is not a warning.
+		e.log(slog.LevelDebug, "engine stopped without a data file loaded")
+		removeTempDir()
 	}
 }
 
@@ -191,20 +370,20 @@ func (e *Engine) recoverEngine() {
 	// if panic occurs, we will log the error and restart the file pulling
 	defer func() {
 		if r := recover(); r != nil {
-			e.logger.Printf("error occurred when pulling data: %v", r)
-			if !e.isStopped {
+			e.log(slog.LevelError, "file puller failed", "error", r)
+			if !e.isStopped.Load() {
 				go e.ScheduleFilePulling(e.stopCh, e.reloadFileEvents)
 			}
 		}
 	}()
 }
 
-// reloadFileEvent listens for file reload events and triggers processing when an external file change is detected.
+// reloadFileEvent listens for the reload events sent once the file puller has downloaded a new data
+// file, and reloads it. A failed reload is reported and the next download is still reloaded.
 func (e *Engine) reloadFileEvent() {
 	for range e.reloadFileEvents {
-		if err := e.processFileExternallyChanged(); err != nil {
-			return
-		}
+		// A failed reload is logged and reported by reload.
+		_ = e.processFileExternallyChanged(ReloadTriggerAutoUpdate)
 	}
 }
 
@@ -254,100 +433,358 @@ func (e *Engine) hasDefaultDistributorParams() bool {
 }
 
 // processFileExternallyChanged reloads the file if it detects external changes by invoking the reload manager with the file path.
-func (e *Engine) processFileExternallyChanged() error {
-	reloadFilePath, err := e.GetReloadFilePath()
-	if err != nil {
-		return err
+func (e *Engine) processFileExternallyChanged(trigger ReloadTrigger) error {
+	return e.reload(trigger, e.GetDataFile(), func() error {
+		info, statErr := os.Stat(e.GetDataFile())
+		reloadFilePath, err := e.GetReloadFilePath()
+		if err != nil {
+			return err
+		}
+		if statErr == nil {
+			e.keepModTime(reloadFilePath, info.ModTime())
+		}
+
+		return e.reloadManager(reloadFilePath)
+	})
+}
+
+// this function will be called when the engine is started or the is new file available
+// it loads the file into a new staging manager and checks it, see validateManager, before swapping
+// it for the existing manager, so a corrupt or truncated file never replaces the live data set.
+// The existing manager, and the temp copy of the file it was created from, are freed once the last
+// lookup using them has finished. Without temp data copies the staging manager is loaded from the
+// original file. The caller must hold reloadMu, see reload.
+func (e *Engine) reloadManager(filePath string) error {
+	if e.config == nil {
+		e.config = ipi_interop.NewConfigIpi(ipi_interop.Balanced)
+	}
+
+	var size uint64
+	if e.memoryBudget > 0 {
+		var err error
+		size, err = ipi_interop.EstimateManagerSize(*e.config, filePath)
+		if err = e.checkMemoryBudget(size, err); err != nil {
+			if e.IsCreateTempDataCopyEnabled() {
+				os.Remove(filePath)
+			}
+			return err
+		}
+	}
+
+	manager := ipi_interop.NewResourceManager()
+	if err := ipi_interop.InitManagerFromFile(manager, *e.config, strings.Join(e.managerProperties, ","), filePath); err != nil {
+		manager.Free()
+		if e.IsCreateTempDataCopyEnabled() {
+			os.Remove(filePath)
+		}
+		return fmt.Errorf("failed to init manager from file: %w", err)
+	}
+
+	if e.IsCreateTempDataCopyEnabled() {
+		// The temp copy is only removed once nothing can still be reading from it, or straight
+		// away if the file is rejected.
+		manager.OnFree(func() {
+			if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+				e.log(slog.LevelWarn, "failed to remove temp data file", "path", filePath, "error", err)
+			}
+		})
 	}
 
-	if err := e.reloadManager(reloadFilePath); err != nil {
+	if err := e.validateManager(manager); err != nil {
+		manager.Free()
 		return err
 	}
 
+	e.swapManager(manager)
+	e.liveSize.Store(size)
+	e.initPropertyIndexes()
+	e.dataFileLastUsedByManager = filePath
+	if e.IsCreateTempDataCopyEnabled() {
+		e.retainVersion(filePath, ipi_interop.GetPublishedDate(manager))
+	}
+
 	return nil
 }
 
-// this function will be called when the engine is started or the is new file available
-// it will create and initialize a new manager from the new file if it does not exist
-// if the manager exists, it will create a new manager from the new file and replace the existing manager thus freeing memory of the old manager
-func (e *Engine) reloadManager(filePath string) (err error) {
-	if e.isStopped {
-		return nil
-	}
-	// Log the published date only once the (re)load succeeded. On a failed load the
-	// dataset is not attached to the manager, so getPublishedDate would dereference a
-	// null dataset and segfault - the deferred log must not run on the error paths.
-	defer func() {
-		if err != nil {
-			return
-		}
-		year, month, day := e.getPublishedDate().Date()
-		e.logger.Printf("data file loaded from " + filePath + " published on: " + fmt.Sprintf("%d-%d-%d", year, month, day))
-	}()
+// ReloadFromMemory loads a data file which has already been read into memory, replacing the
+// current data set. The data is copied, so it can be reused or discarded once this returns. Lookups
+// which started before the reload keep using the previous data set until they finish.
+// This is how an engine created with WithDataBytes, WithDataReader or WithDataFS is updated, as it
+// has no file to watch or pull updates to.
+func (e *Engine) ReloadFromMemory(data []byte) error {
+	return e.reload(ReloadTriggerManual, "", func() error { return e.reloadFromMemory(data) })
+}
 
-	if e.manager == nil {
-		e.manager = ipi_interop.NewResourceManager()
-		// init manager from file
-		if e.config == nil {
-			e.config = ipi_interop.NewConfigIpi(ipi_interop.Balanced)
-		}
+// reloadFromMemory loads the data file in data, see ReloadFromMemory. The caller must hold
+// reloadMu, see reload.
+func (e *Engine) reloadFromMemory(data []byte) error {
+	if e.config == nil {
+		e.config = ipi_interop.NewConfigIpi(ipi_interop.Balanced)
+	}
 
-		if err := ipi_interop.InitManagerFromFile(e.manager, *e.config, strings.Join(e.managerProperties, ","), filePath); err != nil {
-			return fmt.Errorf("failed to init manager from file: %+v", err)
-		}
-		e.dataFileLastUsedByManager = filePath
-		// return nil is created for the first time
-		return nil
-	} else if !e.IsCreateTempDataCopyEnabled() {
-		err := e.manager.ReloadFromOriginalFile()
-		if err != nil {
-			return fmt.Errorf("failed to reload manager from original file: %w", err)
+	var size uint64
+	if e.memoryBudget > 0 {
+		var err error
+		size, err = ipi_interop.EstimateManagerSizeFromMemory(data)
+		if err = e.checkMemoryBudget(size, err); err != nil {
+			return err
 		}
-		return nil
 	}
 
-	err = e.manager.ReloadFromFile(*e.config, strings.Join(e.managerProperties, ","), filePath)
-	if err != nil {
-		return fmt.Errorf("failed to reload manager from file: %w", err)
+	// The data is loaded into a staging manager and checked before it replaces the live one, whose
+	// memory is freed once the last lookup using it has finished.
+	manager := ipi_interop.NewResourceManager()
+	if err := ipi_interop.InitManagerFromMemory(manager, *e.config, strings.Join(e.managerProperties, ","), data); err != nil {
+		manager.Free()
+		return fmt.Errorf("failed to init manager from memory: %w", err)
 	}
 
-	err = os.Remove(e.dataFileLastUsedByManager)
-	if err != nil {
+	if err := e.validateManager(manager); err != nil {
+		manager.Free()
 		return err
 	}
 
-	e.dataFileLastUsedByManager = filePath
+	e.swapManager(manager)
+	e.liveSize.Store(size)
+	e.initPropertyIndexes()
+	e.dataSize.Store(int64(len(data)))
 
 	return nil
 }
 
+// DatasetInfo describes the data file currently loaded by the engine: when it was published and
+// when the next one is due, its format version, name, path and size, how many properties it has,
+// whether it covers IPv4 and IPv6, and the performance profile it was loaded with. FilePath is the
+// data file given with WithDataFile, even when a temp copy of it is used, and is empty when the
+// data file was loaded from memory.
+func (e *Engine) DatasetInfo() (ipi_interop.DataSetInfo, error) {
+	manager := e.acquireManager()
+	if manager == nil {
+		return ipi_interop.DataSetInfo{}, errNoManager
+	}
+	defer manager.Release()
+
+	info := ipi_interop.GetDataSetInfo(manager)
+	if info.FilePath == "" {
+		info.FileSize = e.dataSize.Load()
+	} else {
+		info.FilePath = e.GetDataFile()
+	}
+	if e.config != nil {
+		info.PerformanceProfile = e.config.PerformanceProfile()
+	}
+	return info, nil
+}
+
+// checkMemoryBudget returns an error if a new data set, together with the live one which stays in
+// memory until the last lookup using it has finished, is estimated to need more memory than the
+// budget set with WithMemoryBudget, or if the new data set's size could not be estimated.
+func (e *Engine) checkMemoryBudget(size uint64, err error) error {
+	if err != nil {
+		return fmt.Errorf("failed to estimate the memory needed by the data file: %w", err)
+	}
+	live := e.liveSize.Load()
+	if size > e.memoryBudget || live > e.memoryBudget-size {
+		return fmt.Errorf("%w: the data file needs an estimated %d bytes with the configured profile, and the live data set %d bytes, the budget is %d bytes",
+			ErrMemoryBudgetExceeded, size, live, e.memoryBudget)
+	}
+	return nil
+}
+
+// swapManager atomically replaces the current manager, releasing the engine's reference to the
+// previous one. Lookups which acquired the previous manager keep using it until they finish.
+func (e *Engine) swapManager(manager *ipi_interop.ResourceManager) {
+	if previous := e.manager.Swap(manager); previous != nil {
+		previous.Free()
+	}
+}
+
+// acquireManager returns the current manager with a reference taken, which the caller must
+// release with its Release method. It returns nil if no manager is loaded, either because no data
+// file has been loaded yet or because the engine has been stopped.
+func (e *Engine) acquireManager() *ipi_interop.ResourceManager {
+	for {
+		manager := e.manager.Load()
+		if manager == nil {
+			return nil
+		}
+		if manager.Acquire() {
+			return manager
+		}
+		// The manager was swapped out and freed after it was loaded, so try the new one.
+	}
+}
+
 // getPublishedDate retrieves the published date of the data file being used by the engine.
 func (e *Engine) getPublishedDate() time.Time {
-	return ipi_interop.GetPublishedDate(e.manager)
+	manager := e.acquireManager()
+	if manager == nil {
+		return time.Time{}
+	}
+	defer manager.Release()
+	return ipi_interop.GetPublishedDate(manager)
 }
 
 // NewResultsIpi creates a new ResultsIpi object using this engine's manager
 // Caller is responsible for calling Free() on the returned object
+// The results keep using the data set which was current when they were created, even after a
+// reload, until they are freed. It returns nil if the engine has been stopped, which
+// ProcessWithResults treats as no results given, so the lookup reports the engine is stopped, and
+// which can be freed. Use TryNewResultsIpi to be told why.
 func (e *Engine) NewResultsIpi() *ipi_interop.ResultsIpi {
-	return ipi_interop.NewResultsIpi(e.manager)
+	results, _ := e.TryNewResultsIpi()
+	return results
+}
+
+// TryNewResultsIpi is the same as NewResultsIpi but returns an error if the engine has been
+// stopped, or has no data file loaded, instead of nil results.
+func (e *Engine) TryNewResultsIpi() (*ipi_interop.ResultsIpi, error) {
+	manager := e.acquireManager()
+	if manager == nil {
+		return nil, errNoManager
+	}
+	defer manager.Release()
+	return ipi_interop.NewResultsIpi(manager), nil
 }
 
 // Process processes the given IP address and retrieves associated values using the default properties.
 // If results is nil, creates a new ResultsIpi object for this call (per-call mode).
 // If results is provided, reuses the existing object (reuse mode for better performance).
+// If the data file holds no result for the address, nil values and a nil error are returned. To
+// find out why, pass results to ProcessWithResults and call results.NoValueError, or
+// NoValueReason for a single property.
 func (e *Engine) Process(ipAddress string) (ipi_interop.Values, error) {
 	return e.ProcessWithResults(ipAddress, nil)
 }
 
+// ProcessContext is the same as Process but honours the cancellation and deadline of ctx.
+// If ctx is done before a lookup slot becomes free, ctx.Err() is returned.
+func (e *Engine) ProcessContext(ctx context.Context, ipAddress string) (ipi_interop.Values, error) {
+	return e.ProcessWithResultsContext(ctx, ipAddress, nil)
+}
+
 // ProcessWithResults processes the given IP address with an optional reusable ResultsIpi object.
 // If results is nil, creates a new ResultsIpi object for this call.
 // If results is provided, reuses it for better performance (caller manages lifecycle).
 func (e *Engine) ProcessWithResults(ipAddress string, results *ipi_interop.ResultsIpi) (ipi_interop.Values, error) {
+	return e.ProcessWithResultsContext(context.Background(), ipAddress, results)
+}
+
+// ProcessWithResultsContext is the same as ProcessWithResults but honours the cancellation and
+// deadline of ctx. If ctx is already done, or is done while waiting for a free slot in the C
+// collections, ctx.Err() is returned and no lookup is performed.
+func (e *Engine) ProcessWithResultsContext(ctx context.Context, ipAddress string, results *ipi_interop.ResultsIpi) (ipi_interop.Values, error) {
+	return e.processString(ctx, lookupPathFor(results), ipAddress, results)
+}
+
+// ProcessAddr processes the given IP address without converting it to a string first.
+// IPv4-mapped IPv6 addresses are processed as the IPv4 address they map, and any zone is ignored.
+func (e *Engine) ProcessAddr(addr netip.Addr) (ipi_interop.Values, error) {
+	return e.ProcessAddrWithResults(addr, nil)
+}
+
+// ProcessAddrWithResults is the same as ProcessAddr but with an optional reusable ResultsIpi object,
+// see ProcessWithResults.
+func (e *Engine) ProcessAddrWithResults(addr netip.Addr, results *ipi_interop.ResultsIpi) (ipi_interop.Values, error) {
+	if !addr.IsValid() {
+		e.observeLookup(lookupPathFor(results), time.Now(), errInvalidAddr)
+		return nil, errInvalidAddr
+	}
+	return e.processBytes(context.Background(), lookupPathFor(results), addr.AsSlice(), results)
+}
+
+// ProcessIP processes the given net.IP without converting it to a string first.
+// Both the 4 and 16 byte forms of an IPv4 address give the same result.
+func (e *Engine) ProcessIP(ip net.IP) (ipi_interop.Values, error) {
+	if len(ip) != net.IPv4len && len(ip) != net.IPv6len {
+		e.observeLookup(LookupPathProcess, time.Now(), errInvalidAddr)
+		return nil, errInvalidAddr
+	}
+	return e.processBytes(context.Background(), LookupPathProcess, ip, nil)
+}
+
+// ProcessEvidence processes the given evidence, for example "server.client-ip", "query.client-ip-51d"
+// or HTTP headers, applying the same precedence rules as the other 51Degrees language APIs: query
+// evidence takes precedence over server evidence. The caller remains responsible for freeing evidence.
+func (e *Engine) ProcessEvidence(evidence *ipi_interop.Evidence) (ipi_interop.Values, error) {
+	return e.ProcessEvidenceWithResults(evidence, nil)
+}
+
+// ProcessEvidenceWithResults is the same as ProcessEvidence but with an optional reusable ResultsIpi
+// object, see ProcessWithResults.
+func (e *Engine) ProcessEvidenceWithResults(evidence *ipi_interop.Evidence, results *ipi_interop.ResultsIpi) (ipi_interop.Values, error) {
+	if evidence == nil || evidence.CPtr == nil {
+		e.observeLookup(lookupPathFor(results), time.Now(), errNilEvidence)
+		return nil, errNilEvidence
+	}
+	return e.process(context.Background(), lookupPathFor(results), results, func(r *ipi_interop.ResultsIpi) error {
+		return r.ResultsIpiFromEvidence(evidence)
+	})
+}
+
+// ErrMemoryBudgetExceeded is returned when creating the engine, or reloading its data file, if the
+// data file is estimated to need more memory than the budget set with WithMemoryBudget.
+var ErrMemoryBudgetExceeded = errors.New("memory budget exceeded")
+
+// errNilEvidence is returned when nil or already freed evidence is passed to ProcessEvidence.
+var errNilEvidence = &ipi_interop.StatusError{Code: ipi_interop.StatusNullPointer, Message: "evidence is nil or has been freed"}
+
+// errNilResults is returned when nil or already freed results are passed to NoValueReason.
+var errNilResults = &ipi_interop.StatusError{Code: ipi_interop.StatusNullPointer, Message: "results are nil or have been freed"}
+
+// errNoManager is returned when a lookup is made after the engine has been stopped.
+var errNoManager = &ipi_interop.StatusError{Code: ipi_interop.StatusNullPointer, Message: "no data file is loaded, the engine may have been stopped"}
+
+// errInvalidAddr is returned when an address which is neither IPv4 nor IPv6 is passed to ProcessAddr or ProcessIP.
+var errInvalidAddr = &ipi_interop.StatusError{Code: ipi_interop.StatusIncorrectIpAddressFormat, Message: "invalid IP address"}
+
+// processString processes an IP address in string form.
+func (e *Engine) processString(ctx context.Context, path LookupPath, ipAddress string, results *ipi_interop.ResultsIpi) (ipi_interop.Values, error) {
+	return e.process(ctx, path, results, func(r *ipi_interop.ResultsIpi) error {
+		return r.ResultsIpiFromIpAddress(ipAddress)
+	})
+}
+
+// processBytes processes a 4 or 16 byte binary IP address.
+func (e *Engine) processBytes(ctx context.Context, path LookupPath, ipAddress []byte, results *ipi_interop.ResultsIpi) (ipi_interop.Values, error) {
+	return e.process(ctx, path, results, func(r *ipi_interop.ResultsIpi) error {
+		return r.ResultsIpiFromIpAddressBytes(ipAddress)
+	})
+}
+
+// process runs a single lookup, populating results with the given function and reading the values
+// for the engine's properties. If results is nil, a ResultsIpi is created and freed for this call.
+// The lookup is reported to the engine's metrics as made through path.
+func (e *Engine) process(ctx context.Context, path LookupPath, results *ipi_interop.ResultsIpi, populate func(*ipi_interop.ResultsIpi) error) (values ipi_interop.Values, err error) {
+	var noValues bool
+	if e.metrics != nil {
+		start := time.Now()
+		defer func() {
+			// A lookup with no values is not an error, but is counted as no match.
+			if noValues {
+				e.observeLookup(path, start, ipi_interop.ErrNoResults)
+				return
+			}
+			e.observeLookup(path, start, err)
+		}()
+	}
+
+	release, err := e.acquireSlot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
 	var shouldFree bool
 
 	if results == nil {
 		// Create a new ResultsIpi object for this call
-		results = ipi_interop.NewResultsIpi(e.manager)
+		manager := e.acquireManager()
+		if manager == nil {
+			return nil, errNoManager
+		}
+		results = ipi_interop.NewResultsIpi(manager)
+		manager.Release() // the results hold their own reference
 		shouldFree = true // We created it, so we should free it
 	}
 
@@ -355,25 +792,168 @@ func (e *Engine) ProcessWithResults(ipAddress string, results *ipi_interop.Resul
 		defer results.Free() // Ensure proper cleanup only if we created it
 	}
 
-	if err := results.ResultsIpiFromIpAddress(ipAddress); err != nil {
+	if err := populate(results); err != nil {
 		return nil, err
 	}
 
-	var values ipi_interop.Values
-	var err error
+	if !results.HasValues() {
+		noValues = true
+		return nil, nil
+	}
 
-	if results.HasValues() {
-		// OPTIMIZATION: Use pre-computed indexes with Engine's bidirectional property mapping
-		// This eliminates expensive index→name CGO calls by using Engine's readonly cache
-		values, err = results.GetWeightedValuesByIndexes(e.propertyIndexes, e.GetPropertyNameByIndex)
-		if err != nil {
-			return nil, err
-		}
+	// OPTIMIZATION: Use pre-computed indexes with Engine's bidirectional property mapping
+	// This eliminates expensive index→name CGO calls by using Engine's readonly cache
+	caches := e.propertyCachesFor(results)
+	values, err = results.GetWeightedValuesByIndexes(caches.propertyIndexes, caches.propertyName)
+	if err != nil {
+		return nil, err
 	}
 
 	return values, nil
 }
 
+// ProcessTyped is the same as Process but returns the values wrapped with the value type of each
+// property, so they can be read with typed accessors instead of type assertions.
+func (e *Engine) ProcessTyped(ipAddress string) (*ipi_interop.TypedValues, error) {
+	values, err := e.Process(ipAddress)
+	if err != nil {
+		return nil, err
+	}
+	return e.TypedValues(values), nil
+}
+
+// ProcessInto processes the given IP address and decodes the values into the struct pointed to by
+// dst, using the `ipi:"PropertyName"` tags of its fields. See ipi_interop.Values.Decode.
+func (e *Engine) ProcessInto(ipAddress string, dst interface{}) error {
+	values, err := e.Process(ipAddress)
+	if err != nil {
+		return err
+	}
+	return values.Decode(dst)
+}
+
+// TypedValues wraps values returned by any of the Process methods with the value type of each
+// property in the current data set.
+func (e *Engine) TypedValues(values ipi_interop.Values) *ipi_interop.TypedValues {
+	var types map[string]ipi_interop.PropertyValueType
+	if caches := e.properties.Load(); caches != nil {
+		types = caches.propertyTypes
+	}
+	return ipi_interop.NewTypedValues(values, types)
+}
+
+// Properties describes every property in the current data file, including those which were not
+// requested with WithProperties: its name, value type, whether its values are weighted, its
+// description, URL, category and component, and whether it was requested.
+func (e *Engine) Properties() ([]ipi_interop.PropertyMetadata, error) {
+	manager := e.acquireManager()
+	if manager == nil {
+		return nil, errNoManager
+	}
+	defer manager.Release()
+	return ipi_interop.GetPropertyMetadata(manager), nil
+}
+
+// Components describes the components of the current data file, such as Location and Network:
+// their ids and names, the id of the profile used when nothing else matches, and the evidence
+// headers they consider.
+func (e *Engine) Components() ([]ipi_interop.Component, error) {
+	manager := e.acquireManager()
+	if manager == nil {
+		return nil, errNoManager
+	}
+	defer manager.Release()
+	return ipi_interop.GetComponents(manager), nil
+}
+
+// ProfileValues returns the values of the profile at profileOffset in the current data file, for
+// every property of its component whether or not it was requested with WithProperties. Profile
+// offsets are given for a lookup by the MatchedProfiles of the results passed to
+// ProcessWithResults, by Explain and by the DefaultProfileOffset of Components, and let a profile
+// be turned back into values against the data file it came from. An error is returned if no
+// profile of the data file starts at the offset.
+func (e *Engine) ProfileValues(profileOffset uint32) (ipi_interop.Values, error) {
+	manager := e.acquireManager()
+	if manager == nil {
+		return nil, errNoManager
+	}
+	defer manager.Release()
+	return ipi_interop.GetProfileValues(manager, profileOffset)
+}
+
+// PropertyValues returns every distinct value the current data file can return for the named
+// property, such as every RegisteredCountry, with its description and URL where the data file has
+// them. The property does not need to have been requested with WithProperties. An error is
+// returned if the data file has no property with the name.
+func (e *Engine) PropertyValues(name string) ([]ipi_interop.PropertyValue, error) {
+	manager := e.acquireManager()
+	if manager == nil {
+		return nil, errNoManager
+	}
+	defer manager.Release()
+	return ipi_interop.GetPropertyValues(manager, name)
+}
+
+// Explain shows how the current data file reached its answer for an IP address. For each component
+// it returns the offset the graph matched, the profile or profile group it refers to, and the
+// values and raw weightings of each profile. The graph nodes visited are only listed when the
+// module is built with the ipi_graph_trace build tag, as tracing slows every lookup; without it the
+// Traced field of the explanation is false. The bounds of the IP range matched are not returned,
+// as the graphs do not hold them, see ipi_interop.Explain.
+func (e *Engine) Explain(ip string) (*ipi_interop.Explanation, error) {
+	manager := e.acquireManager()
+	if manager == nil {
+		return nil, errNoManager
+	}
+	defer manager.Release()
+	return ipi_interop.Explain(manager, ip)
+}
+
+// NoValueReason explains why the last lookup made with results returned no values for property,
+// for example because the matched range has a null profile for the property's component, or
+// because the property is not in this data file tier. results must be the object passed to the
+// last ProcessWithResults call. NoValueReasonUnknown is returned if the property does have values.
+func (e *Engine) NoValueReason(results *ipi_interop.ResultsIpi, property string) (ipi_interop.NoValueReason, error) {
+	if results == nil || results.CPtr == nil {
+		return ipi_interop.NoValueReasonUnknown, errNilResults
+	}
+	index, ok := e.propertyCachesFor(results).propertyIndexCache[property]
+	if !ok || index < 0 {
+		return ipi_interop.NoValueReasonInvalidProperty, nil
+	}
+	return results.GetNoValueReason(index)
+}
+
+// initSlots sizes the lookup slots to the concurrency of the configured C collections.
+// Collections which are fully loaded into memory report zero and leave lookups unbounded.
+func (e *Engine) initSlots() {
+	if e.config == nil {
+		return
+	}
+	if n := e.config.Concurrency(); n > 0 {
+		e.slots = make(chan struct{}, n)
+	}
+}
+
+// acquireSlot waits for a free lookup slot, giving up with ctx.Err() if ctx is done first.
+// The returned function must be called to release the slot once the lookup has finished.
+func (e *Engine) acquireSlot(ctx context.Context) (func(), error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if e.slots == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case e.slots <- struct{}{}:
+		return func() { <-e.slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
 // appendLicenceKey appends the license key as a query parameter to the data file URL in the Engine instance.
 func (e *Engine) appendLicenceKey() error {
 	urlParsed, err := url.Parse(e.GetDataFileUrl())
@@ -389,59 +969,103 @@ func (e *Engine) appendLicenceKey() error {
 	return nil
 }
 
-// initPropertyIndexes pre-computes bidirectional property index↔name caches.
-// It creates a temporary ResultsIpi to resolve property names to their numeric
-// required-property indexes, then delegates to initPropertyIndexesWithIndexer.
+// initPropertyIndexes pre-computes the property caches of the current data set and swaps them in.
+// It creates a ResultsIpi to resolve property names to their numeric required-property indexes,
+// which the caches keep to pin the data set, then delegates to initPropertyIndexesWithIndexer.
 func (e *Engine) initPropertyIndexes() {
-	r := ipi_interop.NewResultsIpi(e.manager)
-	defer r.Free()
-	e.initPropertyIndexesWithIndexer(r)
+	manager := e.acquireManager()
+	if manager == nil {
+		return
+	}
+	defer manager.Release()
+
+	r := ipi_interop.NewResultsIpi(manager)
+	caches := e.initPropertyIndexesWithIndexer(r)
+	caches.results = r
+	e.storePropertyCaches(caches)
 }
 
-// initPropertyIndexesWithIndexer seeds the engine's bidirectional name↔index
-// caches using the provided indexer to resolve each property name.
+// initPropertyIndexesWithIndexer builds the bidirectional name↔index caches of
+// the dataset the indexer was created from, using the indexer to resolve each
+// property name. The declared value type of every property is cached alongside
+// for TypedValues.
 //
 // Two modes of operation:
 //
 //   - Empty managerProperties (nil or zero-length): the C engine was initialized
 //     with an empty properties string, which signals "load all properties".
-//     This function enumerates every available property from the live dataset via
-//     availablePropertyNamesProvider and populates only the name caches.
+//     This function enumerates every available property from the dataset via
+//     the indexer and populates only the name caches.
 //     propertyIndexes is left nil so that ProcessWithResults passes a NULL index
 //     array to the C layer, which responds by returning all available properties.
 //
 //   - Explicit managerProperties: builds the propertyIndexes slice used to
 //     request exactly those properties from the C layer, and seeds the caches for
 //     fast index→name resolution during result assembly.
-func (e *Engine) initPropertyIndexesWithIndexer(indexer resultsPropertyIndexer) {
+func (e *Engine) initPropertyIndexesWithIndexer(indexer resultsPropertyIndexer) *propertyCaches {
+	caches := &propertyCaches{
+		dataSet:            indexer.DataSetID(),
+		propertyIndexCache: make(map[string]int),
+		propertyNameCache:  make(map[int]string),
+		propertyTypes:      indexer.PropertyValueTypes(),
+	}
+
 	if len(e.managerProperties) == 0 {
 		// All-properties mode: enumerate the dataset to seed the name cache so
 		// GetPropertyNameByIndex can resolve every index the C engine returns.
-		for _, prop := range availablePropertyNamesProvider(e.manager) {
+		for _, prop := range indexer.AvailablePropertyNames() {
 			idx := indexer.GetPropertyIndexByName(prop)
-			e.propertyIndexCache[prop] = idx
-			e.propertyNameCache[idx] = prop
+			caches.propertyIndexCache[prop] = idx
+			caches.propertyNameCache[idx] = prop
 		}
 		// propertyIndexes stays nil → C gets NULL → all available properties returned.
-		return
+		return caches
 	}
 
 	// Explicit-properties mode: build the index list for targeted C queries.
-	e.propertyIndexes = make([]int, len(e.managerProperties))
+	caches.propertyIndexes = make([]int, len(e.managerProperties))
 	for i, prop := range e.managerProperties {
 		idx := indexer.GetPropertyIndexByName(prop)
-		e.propertyIndexes[i] = idx
-		e.propertyIndexCache[prop] = idx
-		e.propertyNameCache[idx] = prop
+		caches.propertyIndexes[i] = idx
+		caches.propertyIndexCache[prop] = idx
+		caches.propertyNameCache[idx] = prop
 	}
+	return caches
+}
+
+// storePropertyCaches swaps in the caches of a new current data set, freeing the results which
+// pinned the previous one.
+func (e *Engine) storePropertyCaches(caches *propertyCaches) {
+	if previous := e.properties.Swap(caches); previous != nil && previous.results != nil {
+		previous.results.Free()
+	}
+}
+
+// propertyCachesFor returns the property caches of the data set the results were created from.
+// These are the current caches unless the results were created before the last reload, in which
+// case caches are built for this lookup alone so values are never named from another data set.
+func (e *Engine) propertyCachesFor(results resultsPropertyIndexer) *propertyCaches {
+	if caches := e.properties.Load(); caches != nil && caches.dataSet == results.DataSetID() {
+		return caches
+	}
+	return e.initPropertyIndexesWithIndexer(results)
+}
+
+// propertyName returns the property name for the given required-property index, or an empty
+// string for unknown indexes.
+func (c *propertyCaches) propertyName(index int) string {
+	if name, exists := c.propertyNameCache[index]; exists {
+		return name
+	}
+	return "" // Unknown index
 }
 
 // GetPropertyNameByIndex returns the property name for the given required-property
-// index from the engine's read-only post-init cache. Returns an empty string for
+// index from the read-only cache of the current data set. Returns an empty string for
 // unknown indexes; the caller falls back to a CGO lookup in that case.
 func (e *Engine) GetPropertyNameByIndex(index int) string {
-	if name, exists := e.propertyNameCache[index]; exists {
-		return name
+	if caches := e.properties.Load(); caches != nil {
+		return caches.propertyName(index)
 	}
-	return "" // Unknown index
+	return "" // No data set loaded
 }
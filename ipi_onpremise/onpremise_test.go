@@ -1,10 +1,19 @@
 package ipi_onpremise
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
 	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	common_go "github.com/51Degrees/common-go/v4"
 	"github.com/51Degrees/ip-intelligence-go/v4/ipi_interop"
@@ -13,7 +22,16 @@ import (
 // testPropertyIndexer is a mock for the resultsPropertyIndexer interface that
 // maps property names to fixed indexes without any CGO dependency.
 type testPropertyIndexer struct {
+	dataSet     uintptr
+	names       []string
 	nameToIndex map[string]int
+	types       map[string]ipi_interop.PropertyValueType
+
+	namesCalled bool
+}
+
+func (m *testPropertyIndexer) DataSetID() uintptr {
+	return m.dataSet
 }
 
 func (m *testPropertyIndexer) GetPropertyIndexByName(name string) int {
@@ -23,6 +41,22 @@ func (m *testPropertyIndexer) GetPropertyIndexByName(name string) int {
 	return -1
 }
 
+func (m *testPropertyIndexer) AvailablePropertyNames() []string {
+	m.namesCalled = true
+	return m.names
+}
+
+func (m *testPropertyIndexer) PropertyValueTypes() map[string]ipi_interop.PropertyValueType {
+	return m.types
+}
+
+// newTestEngine returns an engine whose current data set has the given property caches.
+func newTestEngine(caches *propertyCaches) *Engine {
+	engine := &Engine{}
+	engine.properties.Store(caches)
+	return engine
+}
+
 // Mock implementations for testing
 type mockResourceManager struct {
 	freed           bool
@@ -97,6 +131,32 @@ func TestNew(t *testing.T) {
 			expectError: true,
 			errorMsg:    "failed to get file path",
 		},
+		{
+			name: "corrupt data file contents",
+			options: []EngineOptions{
+				WithDataBytes([]byte("not a data file")),
+			},
+			expectError: true,
+			errorMsg:    "failed to init manager from memory",
+		},
+		{
+			name: "data file path and contents",
+			options: []EngineOptions{
+				WithDataFile(tmpFile),
+				WithDataBytes([]byte("not a data file")),
+			},
+			expectError: true,
+			errorMsg:    errDataFileAndInBytes.Error(),
+		},
+		{
+			name: "corrupt data file contents with memory budget",
+			options: []EngineOptions{
+				WithDataBytes([]byte("not a data file")),
+				WithMemoryBudget(1 << 30),
+			},
+			expectError: true,
+			errorMsg:    "failed to estimate the memory needed by the data file",
+		},
 	}
 
 	for _, tt := range tests {
@@ -363,8 +423,7 @@ func TestEngine_validateAndAppendUrlParams(t *testing.T) {
 
 func TestEngine_recoverEngine(t *testing.T) {
 	engine := &Engine{
-		logger:    &common_go.LogWrapper{},
-		isStopped: false,
+		logger: &common_go.LogWrapper{},
 	}
 
 	// Test that recoverEngine doesn't panic
@@ -406,9 +465,9 @@ func TestEngine_GetPropertyNameByIndex(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			engine := &Engine{
+			engine := newTestEngine(&propertyCaches{
 				propertyNameCache: tt.cache,
-			}
+			})
 
 			result := engine.GetPropertyNameByIndex(tt.index)
 			if result != tt.expected {
@@ -420,25 +479,19 @@ func TestEngine_GetPropertyNameByIndex(t *testing.T) {
 
 // TestInitPropertyIndexesWithIndexer_EmptyProperties confirms that when
 // managerProperties is nil or empty the engine:
-//   - calls availablePropertyNamesProvider to discover all property names,
+//   - asks the dataset for all available property names,
 //   - populates both name caches from those names, and
 //   - leaves propertyIndexes nil so that GetWeightedValuesByIndexes passes NULL
 //     to the C layer (which then returns all available properties).
 func TestInitPropertyIndexesWithIndexer_EmptyProperties(t *testing.T) {
-	// Inject deterministic property names without requiring a real ResourceManager.
-	origProvider := availablePropertyNamesProvider
-	availablePropertyNamesProvider = func(_ *ipi_interop.ResourceManager) []string {
-		return []string{"IpRangeStart", "Country", "City"}
-	}
-	defer func() { availablePropertyNamesProvider = origProvider }()
-
 	engine := &Engine{
-		managerProperties:  nil,
-		propertyIndexCache: make(map[string]int),
-		propertyNameCache:  make(map[int]string),
+		managerProperties: nil,
 	}
 
+	// Inject deterministic property names without requiring a real ResourceManager.
 	mock := &testPropertyIndexer{
+		dataSet: 1,
+		names:   []string{"IpRangeStart", "Country", "City"},
 		nameToIndex: map[string]int{
 			"IpRangeStart": 0,
 			"Country":      3,
@@ -446,12 +499,16 @@ func TestInitPropertyIndexesWithIndexer_EmptyProperties(t *testing.T) {
 		},
 	}
 
-	engine.initPropertyIndexesWithIndexer(mock)
+	caches := engine.initPropertyIndexesWithIndexer(mock)
+	engine.storePropertyCaches(caches)
 
 	// propertyIndexes must remain nil: a nil slice causes GetWeightedValuesByIndexes
 	// to pass a NULL index array to the C layer, which returns all properties.
-	if engine.propertyIndexes != nil {
-		t.Errorf("propertyIndexes should be nil in all-properties mode, got %v", engine.propertyIndexes)
+	if caches.propertyIndexes != nil {
+		t.Errorf("propertyIndexes should be nil in all-properties mode, got %v", caches.propertyIndexes)
+	}
+	if caches.dataSet != mock.dataSet {
+		t.Errorf("dataSet = %d, want %d", caches.dataSet, mock.dataSet)
 	}
 
 	// Every property provided by the mock dataset must be resolvable by name.
@@ -465,7 +522,7 @@ func TestInitPropertyIndexesWithIndexer_EmptyProperties(t *testing.T) {
 	// Every property must also be findable by name in the index cache.
 	wantIndexCache := map[string]int{"IpRangeStart": 0, "Country": 3, "City": 7}
 	for name, idx := range wantIndexCache {
-		if got, ok := engine.propertyIndexCache[name]; !ok || got != idx {
+		if got, ok := caches.propertyIndexCache[name]; !ok || got != idx {
 			t.Errorf("propertyIndexCache[%q] = %d, want %d", name, got, idx)
 		}
 	}
@@ -478,9 +535,7 @@ func TestInitPropertyIndexesWithIndexer_EmptyProperties(t *testing.T) {
 func TestInitPropertyIndexesWithIndexer_ExplicitProperties(t *testing.T) {
 	props := []string{"IpRangeStart", "Country"}
 	engine := &Engine{
-		managerProperties:  props,
-		propertyIndexCache: make(map[string]int),
-		propertyNameCache:  make(map[int]string),
+		managerProperties: props,
 	}
 
 	mock := &testPropertyIndexer{
@@ -490,16 +545,17 @@ func TestInitPropertyIndexesWithIndexer_ExplicitProperties(t *testing.T) {
 		},
 	}
 
-	engine.initPropertyIndexesWithIndexer(mock)
+	caches := engine.initPropertyIndexesWithIndexer(mock)
+	engine.storePropertyCaches(caches)
 
-	if len(engine.propertyIndexes) != len(props) {
-		t.Fatalf("expected %d propertyIndexes, got %d", len(props), len(engine.propertyIndexes))
+	if len(caches.propertyIndexes) != len(props) {
+		t.Fatalf("expected %d propertyIndexes, got %d", len(props), len(caches.propertyIndexes))
 	}
-	if engine.propertyIndexes[0] != 0 {
-		t.Errorf("propertyIndexes[0] = %d, want 0", engine.propertyIndexes[0])
+	if caches.propertyIndexes[0] != 0 {
+		t.Errorf("propertyIndexes[0] = %d, want 0", caches.propertyIndexes[0])
 	}
-	if engine.propertyIndexes[1] != 3 {
-		t.Errorf("propertyIndexes[1] = %d, want 3", engine.propertyIndexes[1])
+	if caches.propertyIndexes[1] != 3 {
+		t.Errorf("propertyIndexes[1] = %d, want 3", caches.propertyIndexes[1])
 	}
 
 	if got := engine.GetPropertyNameByIndex(0); got != "IpRangeStart" {
@@ -508,62 +564,81 @@ func TestInitPropertyIndexesWithIndexer_ExplicitProperties(t *testing.T) {
 	if got := engine.GetPropertyNameByIndex(3); got != "Country" {
 		t.Errorf("GetPropertyNameByIndex(3) = %q, want Country", got)
 	}
-	if got, ok := engine.propertyIndexCache["IpRangeStart"]; !ok || got != 0 {
+	if got, ok := caches.propertyIndexCache["IpRangeStart"]; !ok || got != 0 {
 		t.Errorf("propertyIndexCache[IpRangeStart] = %d, want 0", got)
 	}
 }
 
-// TestAvailablePropertyNamesProviderCalledForEmpty verifies that
-// availablePropertyNamesProvider is invoked when managerProperties is empty,
-// and is not invoked when an explicit list is provided.
-func TestAvailablePropertyNamesProviderCalledForEmpty(t *testing.T) {
+// TestAvailablePropertyNamesCalledForEmpty verifies that the available
+// property names are enumerated when managerProperties is empty, and are not
+// when an explicit list is provided.
+func TestAvailablePropertyNamesCalledForEmpty(t *testing.T) {
 	t.Run("called when empty", func(t *testing.T) {
-		called := false
-		orig := availablePropertyNamesProvider
-		availablePropertyNamesProvider = func(_ *ipi_interop.ResourceManager) []string {
-			called = true
-			return []string{"TestProp"}
-		}
-		defer func() { availablePropertyNamesProvider = orig }()
-
 		engine := &Engine{
-			managerProperties:  nil,
-			propertyIndexCache: make(map[string]int),
-			propertyNameCache:  make(map[int]string),
+			managerProperties: nil,
 		}
-		engine.initPropertyIndexesWithIndexer(&testPropertyIndexer{
+		mock := &testPropertyIndexer{
+			names:       []string{"TestProp"},
 			nameToIndex: map[string]int{"TestProp": 0},
-		})
+		}
+		engine.initPropertyIndexesWithIndexer(mock)
 
-		if !called {
-			t.Error("availablePropertyNamesProvider should be called when managerProperties is nil")
+		if !mock.namesCalled {
+			t.Error("AvailablePropertyNames should be called when managerProperties is nil")
 		}
 	})
 
 	t.Run("not called when explicit", func(t *testing.T) {
-		called := false
-		orig := availablePropertyNamesProvider
-		availablePropertyNamesProvider = func(_ *ipi_interop.ResourceManager) []string {
-			called = true
-			return nil
-		}
-		defer func() { availablePropertyNamesProvider = orig }()
-
 		engine := &Engine{
-			managerProperties:  []string{"IpRangeStart"},
-			propertyIndexCache: make(map[string]int),
-			propertyNameCache:  make(map[int]string),
+			managerProperties: []string{"IpRangeStart"},
 		}
-		engine.initPropertyIndexesWithIndexer(&testPropertyIndexer{
+		mock := &testPropertyIndexer{
 			nameToIndex: map[string]int{"IpRangeStart": 0},
-		})
+		}
+		engine.initPropertyIndexesWithIndexer(mock)
 
-		if called {
-			t.Error("availablePropertyNamesProvider should not be called when managerProperties is explicit")
+		if mock.namesCalled {
+			t.Error("AvailablePropertyNames should not be called when managerProperties is explicit")
 		}
 	})
 }
 
+// TestPropertyCachesFor verifies that values are always named from the caches
+// of the dataset the results were created from: the current caches are used
+// for current results, and results from a dataset which has since been
+// replaced get caches of their own, leaving the current caches in place.
+func TestPropertyCachesFor(t *testing.T) {
+	engine := &Engine{}
+
+	// The properties of the newer data file are listed in a different order.
+	current := &testPropertyIndexer{
+		dataSet:     2,
+		names:       []string{"Country", "City"},
+		nameToIndex: map[string]int{"Country": 0, "City": 1},
+	}
+	engine.storePropertyCaches(engine.initPropertyIndexesWithIndexer(current))
+	stale := &testPropertyIndexer{
+		dataSet:     1,
+		names:       []string{"City", "Country"},
+		nameToIndex: map[string]int{"City": 0, "Country": 1},
+	}
+
+	if got := engine.propertyCachesFor(current); got != engine.properties.Load() {
+		t.Error("propertyCachesFor(current) did not return the current caches")
+	}
+
+	caches := engine.propertyCachesFor(stale)
+	if caches == engine.properties.Load() {
+		t.Fatal("propertyCachesFor(stale) returned the current caches")
+	}
+	if got := caches.propertyName(0); got != "City" {
+		t.Errorf("stale propertyName(0) = %q, want City", got)
+	}
+	if got := engine.GetPropertyNameByIndex(0); got != "Country" {
+		t.Errorf("GetPropertyNameByIndex(0) = %q, want Country", got)
+	}
+}
+
 func TestConstants(t *testing.T) {
 	// Test that defaultDataFileUrl is properly defined
 	if defaultDataFileUrl != "" {
@@ -622,7 +697,7 @@ func BenchmarkEngine_hasDefaultDistributorParams(b *testing.B) {
 }
 
 func BenchmarkEngine_GetPropertyNameByIndex(b *testing.B) {
-	engine := &Engine{
+	engine := newTestEngine(&propertyCaches{
 		propertyNameCache: map[int]string{
 			0: "IpRangeStart",
 			1: "IpRangeEnd",
@@ -630,7 +705,7 @@ func BenchmarkEngine_GetPropertyNameByIndex(b *testing.B) {
 			3: "City",
 			4: "Latitude",
 		},
-	}
+	})
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
@@ -651,7 +726,7 @@ func TestEngine_Integration(t *testing.T) {
 
 // Test for race conditions
 func TestEngine_ConcurrentAccess(t *testing.T) {
-	engine := &Engine{
+	engine := newTestEngine(&propertyCaches{
 		propertyNameCache: map[int]string{
 			0: "IpRangeStart",
 			1: "Country",
@@ -660,7 +735,7 @@ func TestEngine_ConcurrentAccess(t *testing.T) {
 			"IpRangeStart": 0,
 			"Country":      1,
 		},
-	}
+	})
 
 	var wg sync.WaitGroup
 	numGoroutines := 10
@@ -679,3 +754,507 @@ func TestEngine_ConcurrentAccess(t *testing.T) {
 
 	wg.Wait()
 }
+
+func TestEngine_acquireSlot(t *testing.T) {
+	t.Run("unbounded when no slots", func(t *testing.T) {
+		engine := &Engine{}
+
+		release, err := engine.acquireSlot(context.Background())
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		release()
+	})
+
+	t.Run("released slot can be reacquired", func(t *testing.T) {
+		engine := &Engine{slots: make(chan struct{}, 1)}
+
+		release, err := engine.acquireSlot(context.Background())
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		release()
+
+		release, err = engine.acquireSlot(context.Background())
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		release()
+	})
+
+	t.Run("deadline while waiting for a slot", func(t *testing.T) {
+		engine := &Engine{slots: make(chan struct{}, 1)}
+		engine.slots <- struct{}{} // occupy the only slot
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		if _, err := engine.acquireSlot(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected %v, got %v", context.DeadlineExceeded, err)
+		}
+	})
+}
+
+func TestEngine_ProcessContext_Cancelled(t *testing.T) {
+	// A cancelled context must be reported before the engine touches the manager,
+	// so an engine without a loaded data file is sufficient here.
+	engine := &Engine{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	values, err := engine.ProcessContext(ctx, "185.28.167.77")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected %v, got %v", context.Canceled, err)
+	}
+	if values != nil {
+		t.Errorf("Expected nil values, got %v", values)
+	}
+}
+
+func TestEngine_ProcessAddr_Invalid(t *testing.T) {
+	// Invalid addresses are rejected before the engine touches the manager.
+	engine := &Engine{}
+
+	if _, err := engine.ProcessAddr(netip.Addr{}); !errors.Is(err, errInvalidAddr) {
+		t.Errorf("ProcessAddr(zero Addr) error = %v, want %v", err, errInvalidAddr)
+	}
+	if _, err := engine.ProcessIP(nil); !errors.Is(err, errInvalidAddr) {
+		t.Errorf("ProcessIP(nil) error = %v, want %v", err, errInvalidAddr)
+	}
+	if _, err := engine.ProcessIP(net.IP{1, 2, 3}); !errors.Is(err, ipi_interop.ErrInvalidIpAddress) {
+		t.Errorf("ProcessIP(3 bytes) error = %v, want %v", err, ipi_interop.ErrInvalidIpAddress)
+	}
+}
+
+func TestEngine_ProcessEvidence_Nil(t *testing.T) {
+	// Missing evidence is rejected before the engine touches the manager.
+	engine := &Engine{}
+
+	if _, err := engine.ProcessEvidence(nil); !errors.Is(err, errNilEvidence) {
+		t.Errorf("ProcessEvidence(nil) error = %v, want %v", err, errNilEvidence)
+	}
+
+	evidence := ipi_interop.NewEvidence()
+	evidence.Free()
+	if _, err := engine.ProcessEvidence(evidence); !errors.Is(err, errNilEvidence) {
+		t.Errorf("ProcessEvidence(freed) error = %v, want %v", err, errNilEvidence)
+	}
+}
+
+func TestEngine_NoValueReason_NilResults(t *testing.T) {
+	engine := newTestEngine(&propertyCaches{
+		propertyIndexCache: map[string]int{"Country": 1},
+	})
+
+	for name, results := range map[string]*ipi_interop.ResultsIpi{"nil": nil, "freed": {}} {
+		if _, err := engine.NoValueReason(results, "Country"); !errors.Is(err, errNilResults) {
+			t.Errorf("NoValueReason(%s) error = %v, want %v", name, err, errNilResults)
+		}
+	}
+}
+
+func TestEngine_TypedValues(t *testing.T) {
+	// The cached value types are applied to values from any Process method.
+	engine := newTestEngine(&propertyCaches{
+		propertyTypes: map[string]ipi_interop.PropertyValueType{
+			"Country":        ipi_interop.StringValueType,
+			"AccuracyRadius": ipi_interop.IntegerValueType,
+		},
+	})
+
+	values := ipi_interop.Values{}
+	values.AppendWithWeight("Country", "France", 1.0)
+	values.AppendWithWeight("AccuracyRadius", 500, 1.0)
+	typed := engine.TypedValues(values)
+
+	if radius, err := typed.Int("AccuracyRadius"); err != nil || radius != 500 {
+		t.Errorf("Int(AccuracyRadius) = %v, %v, want 500, nil", radius, err)
+	}
+	if _, err := typed.Int("Country"); err == nil {
+		t.Error("Int(Country) expected a type error but got none")
+	}
+	if _, err := typed.String("City"); !errors.Is(err, ipi_interop.ErrInvalidProperty) {
+		t.Errorf("String(City) error = %v, want %v", err, ipi_interop.ErrInvalidProperty)
+	}
+}
+
+func TestEngine_swapManager_Concurrent(t *testing.T) {
+	// Managers swapped out while lookups hold them must stay alive until released, and every
+	// manager must be freed once the last holder releases it. Run with -race.
+	engine := &Engine{}
+
+	const swaps = 200
+	var freed atomic.Int64
+	newManager := func() *ipi_interop.ResourceManager {
+		manager := ipi_interop.NewResourceManager()
+		manager.OnFree(func() { freed.Add(1) })
+		return manager
+	}
+	engine.swapManager(newManager())
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				manager := engine.acquireManager()
+				if manager == nil {
+					t.Error("acquireManager() returned nil while a manager is loaded")
+					return
+				}
+				if manager.CPtr == nil {
+					t.Error("acquired manager has already been freed")
+				}
+				manager.Release()
+			}
+		}()
+	}
+
+	for i := 0; i < swaps; i++ {
+		engine.swapManager(newManager())
+	}
+	close(stop)
+	wg.Wait()
+
+	if manager := engine.manager.Swap(nil); manager != nil {
+		manager.Free()
+	}
+	if engine.acquireManager() != nil {
+		t.Error("acquireManager() returned a manager after it was removed")
+	}
+	if got := freed.Load(); got != swaps+1 {
+		t.Errorf("Expected %d managers to be freed, got %d", swaps+1, got)
+	}
+}
+
+func TestEngine_Process_NoManager(t *testing.T) {
+	// Lookups after the manager has been released report an error rather than using freed memory.
+	engine := &Engine{}
+
+	if _, err := engine.Process("192.168.0.1"); !errors.Is(err, errNoManager) {
+		t.Errorf("Process() error = %v, want %v", err, errNoManager)
+	}
+	if _, err := engine.DatasetInfo(); !errors.Is(err, errNoManager) {
+		t.Errorf("DatasetInfo() error = %v, want %v", err, errNoManager)
+	}
+	if _, err := engine.Properties(); !errors.Is(err, errNoManager) {
+		t.Errorf("Properties() error = %v, want %v", err, errNoManager)
+	}
+	if _, err := engine.Components(); !errors.Is(err, errNoManager) {
+		t.Errorf("Components() error = %v, want %v", err, errNoManager)
+	}
+	if _, err := engine.ProfileValues(1); !errors.Is(err, errNoManager) {
+		t.Errorf("ProfileValues() error = %v, want %v", err, errNoManager)
+	}
+	if _, err := engine.PropertyValues("RegisteredCountry"); !errors.Is(err, errNoManager) {
+		t.Errorf("PropertyValues() error = %v, want %v", err, errNoManager)
+	}
+	if _, err := engine.Explain("192.168.0.1"); !errors.Is(err, errNoManager) {
+		t.Errorf("Explain() error = %v, want %v", err, errNoManager)
+	}
+}
+
+func TestCheckMemoryBudget(t *testing.T) {
+	estimateErr := errors.New("estimate failed")
+
+	tests := []struct {
+		name        string
+		size        uint64
+		liveSize    uint64
+		err         error
+		expectError error
+	}{
+		{name: "under budget", size: 99},
+		{name: "at budget", size: 100},
+		{name: "over budget", size: 101, expectError: ErrMemoryBudgetExceeded},
+		{name: "under budget with live data set", size: 60, liveSize: 39},
+		{name: "at budget with live data set", size: 60, liveSize: 40},
+		{name: "over budget with live data set", size: 60, liveSize: 41, expectError: ErrMemoryBudgetExceeded},
+		{name: "estimate failed", err: estimateErr, expectError: estimateErr},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := &Engine{memoryBudget: 100}
+			engine.liveSize.Store(tt.liveSize)
+
+			err := engine.checkMemoryBudget(tt.size, tt.err)
+			if !errors.Is(err, tt.expectError) || (err == nil) != (tt.expectError == nil) {
+				t.Errorf("Expected error %v, got %v", tt.expectError, err)
+			}
+		})
+	}
+}
+
+func TestReloadTrigger_String(t *testing.T) {
+	tests := []struct {
+		trigger ReloadTrigger
+		want    string
+	}{
+		{ReloadTriggerInitial, "initial"},
+		{ReloadTriggerFileWatcher, "file watcher"},
+		{ReloadTriggerAutoUpdate, "auto update"},
+		{ReloadTriggerManual, "manual"},
+		{ReloadTrigger(-1), "unknown"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.trigger.String(); got != tt.want {
+			t.Errorf("ReloadTrigger(%d).String() = %q, want %q", tt.trigger, got, tt.want)
+		}
+	}
+}
+
+func TestEngine_reload_Events(t *testing.T) {
+	loadErr := errors.New("load failed")
+
+	tests := []struct {
+		name    string
+		trigger ReloadTrigger
+		err     error
+	}{
+		{name: "success", trigger: ReloadTriggerFileWatcher},
+		{name: "failure", trigger: ReloadTriggerManual, err: loadErr},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var called []ReloadEvent
+			engine := &Engine{logger: common_go.NewFileUpdater("").GetLogger()}
+			if err := WithOnReload(func(event ReloadEvent) { called = append(called, event) })(engine); err != nil {
+				t.Fatalf("WithOnReload() error = %v", err)
+			}
+			events := engine.Subscribe()
+
+			err := engine.reload(tt.trigger, "data.ipi", func() error { return tt.err })
+			if !errors.Is(err, tt.err) {
+				t.Errorf("reload() error = %v, want %v", err, tt.err)
+			}
+
+			if len(called) != 1 {
+				t.Fatalf("Expected 1 callback, got %d", len(called))
+			}
+			event := <-events
+			if event != called[0] {
+				t.Errorf("Subscriber got %+v, callback got %+v", event, called[0])
+			}
+			if event.Trigger != tt.trigger || event.FilePath != "data.ipi" || !errors.Is(event.Err, tt.err) {
+				t.Errorf("Unexpected event %+v", event)
+			}
+			if !event.Published.Equal(event.PreviousPublished) {
+				t.Errorf("Published = %v, want %v without a manager", event.Published, event.PreviousPublished)
+			}
+		})
+	}
+}
+
+func TestEngine_Subscribe_Stopped(t *testing.T) {
+	engine := &Engine{logger: common_go.NewFileUpdater("").GetLogger()}
+	events := engine.Subscribe()
+
+	// Stop closes existing subscriptions; later ones are closed straight away.
+	engine.isStopped.Store(true)
+	engine.closeSubscribers()
+
+	if _, ok := <-events; ok {
+		t.Error("Expected the subscription to be closed once stopped")
+	}
+	if _, ok := <-engine.Subscribe(); ok {
+		t.Error("Expected a subscription after stopping to be closed")
+	}
+
+	loaded := false
+	err := engine.reload(ReloadTriggerManual, "", func() error { loaded = true; return nil })
+	if !errors.Is(err, errNoManager) || loaded {
+		t.Errorf("reload() after stopping = %v, loaded %v, want %v without loading", err, loaded, errNoManager)
+	}
+}
+
+func TestEngine_Subscribe_SlowSubscriber(t *testing.T) {
+	engine := &Engine{logger: common_go.NewFileUpdater("").GetLogger()}
+	events := engine.Subscribe()
+
+	// Reloads are not held up by a subscriber which does not read its events.
+	for i := 0; i < reloadEventBuffer+1; i++ {
+		if err := engine.reload(ReloadTriggerManual, "", func() error { return nil }); err != nil {
+			t.Fatalf("reload() error = %v", err)
+		}
+	}
+
+	if got := len(events); got != reloadEventBuffer {
+		t.Errorf("Expected %d buffered events, got %d", reloadEventBuffer, got)
+	}
+}
+
+func TestNextPoll(t *testing.T) {
+	started := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		start  time.Time
+		period time.Duration
+		now    time.Time
+		want   time.Time
+	}{
+		{name: "not started", period: time.Hour, now: started},
+		{name: "no period", start: started, now: started},
+		{name: "just started", start: started, period: time.Hour, now: started, want: started.Add(time.Hour)},
+		{name: "first period", start: started, period: time.Hour, now: started.Add(30 * time.Minute), want: started.Add(time.Hour)},
+		{name: "on a pull", start: started, period: time.Hour, now: started.Add(2 * time.Hour), want: started.Add(3 * time.Hour)},
+		{name: "later period", start: started, period: time.Hour, now: started.Add(150 * time.Minute), want: started.Add(3 * time.Hour)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nextPoll(tt.start, tt.period, tt.now); !got.Equal(tt.want) {
+				t.Errorf("nextPoll() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEngine_Status(t *testing.T) {
+	engine := &Engine{
+		FileUpdater: common_go.NewFileUpdater(""),
+		logger:      common_go.NewFileUpdater("").GetLogger(),
+	}
+	loadErr := errors.New("load failed")
+
+	status := engine.Status()
+	if status.Ready || status.LastUpdateTrigger != "" || status.ConsecutiveFailures != 0 {
+		t.Errorf("Unexpected status before loading %+v", status)
+	}
+
+	for i := 0; i < 2; i++ {
+		engine.reload(ReloadTriggerAutoUpdate, "", func() error { return loadErr })
+	}
+	engine.handleFileExternallyChanged()
+
+	status = engine.Status()
+	if status.Ready {
+		t.Error("Expected the engine not to be ready without a data file")
+	}
+	if status.ConsecutiveFailures != 3 || status.LastUpdateError == "" {
+		t.Errorf("Expected 3 consecutive failures, got %d (%q)", status.ConsecutiveFailures, status.LastUpdateError)
+	}
+	if status.LastUpdateTrigger != ReloadTriggerFileWatcher.String() || status.ExternalChanges != 1 {
+		t.Errorf("Expected a file watcher reload and 1 external change, got %q and %d", status.LastUpdateTrigger, status.ExternalChanges)
+	}
+
+	engine.reload(ReloadTriggerManual, "", func() error { return nil })
+	if status = engine.Status(); status.ConsecutiveFailures != 0 || status.LastUpdateError != "" {
+		t.Errorf("Expected a successful reload to reset the failures, got %+v", status)
+	}
+}
+
+func TestEngine_StatusHandler(t *testing.T) {
+	engine := &Engine{
+		FileUpdater: common_go.NewFileUpdater(""),
+		logger:      common_go.NewFileUpdater("").GetLogger(),
+	}
+
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		recorder := httptest.NewRecorder()
+		engine.StatusHandler().ServeHTTP(recorder, httptest.NewRequest(method, "/ready", nil))
+
+		if recorder.Code != http.StatusServiceUnavailable {
+			t.Errorf("%s: expected status %d without a data file, got %d", method, http.StatusServiceUnavailable, recorder.Code)
+		}
+		if got := recorder.Header().Get("Content-Type"); got != "application/json" {
+			t.Errorf("%s: expected JSON, got %q", method, got)
+		}
+		if method == http.MethodHead {
+			if recorder.Body.Len() != 0 {
+				t.Errorf("HEAD: expected no body, got %q", recorder.Body.String())
+			}
+			continue
+		}
+		var status Status
+		if err := json.Unmarshal(recorder.Body.Bytes(), &status); err != nil {
+			t.Fatalf("Failed to decode status: %v", err)
+		}
+		if status.Ready {
+			t.Error("Expected the served status not to be ready")
+		}
+	}
+}
+
+// newStoppableEngine returns an engine with the channels New creates, but no data file loaded.
+func newStoppableEngine() *Engine {
+	fileUpdater := common_go.NewFileUpdater("")
+	return &Engine{
+		FileUpdater:      fileUpdater,
+		logger:           fileUpdater.GetLogger(),
+		stopCh:           make(chan *sync.WaitGroup),
+		reloadFileEvents: make(chan struct{}),
+	}
+}
+
+func TestEngine_Stop_Idempotent(t *testing.T) {
+	engine := newStoppableEngine()
+	events := engine.Subscribe()
+
+	engine.Stop()
+	engine.Stop()
+	if err := engine.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown() after Stop() error = %v", err)
+	}
+	if _, ok := <-events; ok {
+		t.Error("Expected the subscription to be closed once stopped")
+	}
+	if _, err := engine.Process("192.168.0.1"); !errors.Is(err, errNoManager) {
+		t.Errorf("Process() after Stop() error = %v, want %v", err, errNoManager)
+	}
+
+	// A failed New returns a nil engine, which callers may still stop.
+	var failed *Engine
+	failed.Stop()
+	if err := failed.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown() of a nil engine error = %v", err)
+	}
+}
+
+func TestEngine_Shutdown_Deadline(t *testing.T) {
+	engine := newStoppableEngine()
+	// A file puller which is busy, e.g. downloading, does not take the stop signal straight away.
+	engine.SetFilePullerStarted(true)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := engine.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Shutdown() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if !engine.isStopped.Load() {
+		t.Error("Expected the engine to be stopped for new work straight away")
+	}
+
+	// The shutdown carries on in the background once the puller takes the signal.
+	wg := <-engine.stopCh
+	wg.Done()
+	if err := engine.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown() error = %v", err)
+	}
+}
+
+// readTestDataFile returns the contents of the data file at the path in the DATA_FILE environment
+// variable, which the examples also use, skipping the test when it is not set.
+func readTestDataFile(t *testing.T) []byte {
+	t.Helper()
+	filePath := os.Getenv("DATA_FILE")
+	if filePath == "" {
+		t.Skip("DATA_FILE is not set to an IP Intelligence data file")
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", filePath, err)
+	}
+	return data
+}
@@ -3,6 +3,10 @@ package ipi_onpremise
 import (
 	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/netip"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -13,6 +17,12 @@ import (
 
 type EngineOptions func(cfg *Engine) error
 
+var (
+	// errDataEmpty is the error the C layer gives for empty data, so both match ipi_interop.ErrCorruptData
+	errDataEmpty          = &ipi_interop.StatusError{Code: ipi_interop.StatusCorruptData, Message: ipi_interop.ErrDataEmpty}
+	errDataFileAndInBytes = errors.New("a data file path and data file contents cannot both be provided")
+)
+
 // WithDataFile sets the path to the local data file, this parameter is required to start the engine
 func WithDataFile(path string) EngineOptions {
 	return func(cfg *Engine) error {
@@ -27,6 +37,48 @@ func WithDataFile(path string) EngineOptions {
 	}
 }
 
+// WithDataBytes sets the contents of the data file, for example embedded with go:embed, as an
+// alternative to WithDataFile. The data is copied when the engine is created, so the slice can be
+// reused afterwards. As there is no file on disk, file watching, temp data copies and automatic
+// updates are disabled; use Engine.ReloadFromMemory to load a newer data file. Empty data is
+// rejected with an error matching ipi_interop.ErrCorruptData, as ReloadFromMemory rejects it.
+func WithDataBytes(data []byte) EngineOptions {
+	return func(cfg *Engine) error {
+		if len(data) == 0 {
+			return errDataEmpty
+		}
+
+		cfg.data = data
+		return nil
+	}
+}
+
+// WithDataReader reads the data file from r, for example an object in a blob store, as an
+// alternative to WithDataFile. r is read to the end when the option is applied. See WithDataBytes.
+func WithDataReader(r io.Reader) EngineOptions {
+	return func(cfg *Engine) error {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return fmt.Errorf("failed to read data file: %w", err)
+		}
+
+		return WithDataBytes(data)(cfg)
+	}
+}
+
+// WithDataFS reads the data file called name from fsys, for example an embed.FS, as an alternative
+// to WithDataFile. See WithDataBytes.
+func WithDataFS(fsys fs.FS, name string) EngineOptions {
+	return func(cfg *Engine) error {
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return fmt.Errorf("failed to read data file: %w", err)
+		}
+
+		return WithDataBytes(data)(cfg)
+	}
+}
+
 // WithConfigIpi allows to configure the Ipi matching algorithm.
 // See ipi_interopt.ConfigIpi type for all available settings:
 // PerformanceProfile, Drift, Difference, Concurrency
@@ -98,6 +150,7 @@ func WithPollingInterval(seconds int) EngineOptions {
 func WithLogging(enabled bool) EngineOptions {
 	return func(cfg *Engine) error {
 		cfg.logger = cfg.SetLoggerEnabled(enabled)
+		cfg.loggingDisabled = !enabled
 
 		return nil
 	}
@@ -107,7 +160,23 @@ func WithLogging(enabled bool) EngineOptions {
 func WithCustomLogger(logger common_go.LogWriter) EngineOptions {
 	return func(cfg *Engine) error {
 		cfg.logger = cfg.SetCustomLogger(logger)
+		cfg.logWriter = logger
+		cfg.loggingDisabled = false
+
+		return nil
+	}
+}
 
+// WithSlogLogger logs the engine's events, such as "data file loaded", "reload failed" and
+// "update downloaded", as structured records with logger instead of formatted lines. Messages of the
+// file puller and watcher are passed on too. Like WithCustomLogger, it enables logging if an earlier
+// option disabled it.
+func WithSlogLogger(logger *slog.Logger) EngineOptions {
+	return func(cfg *Engine) error {
+		cfg.slog = logger
+		if logger != nil {
+			cfg.loggingDisabled = false
+		}
 		return nil
 	}
 }
@@ -175,6 +244,25 @@ func WithTempDataDir(dir string) EngineOptions {
 	}
 }
 
+// WithRetainedVersions keeps the last n data files which loaded and passed their checks, besides the
+// live one, in the temp data directory, named by their published date, see RetainedVersion. Data
+// files are told apart by their published date, size and modification time, and the contents are
+// only compared for one which matches a retained data file in all but its modification time.
+// Engine.Rollback, Engine.LoadVersion and Engine.LoadRetainedVersion switch back to them, for
+// example when a bad update reaches production. They are removed with the temp data directory when
+// the engine is stopped, so they do not survive a restart of the process: a new engine starts with
+// none. It needs temp data copies, so cannot be used with WithTempDataCopy(false) or a data file
+// loaded from memory. Default is 0.
+func WithRetainedVersions(n int) EngineOptions {
+	return func(cfg *Engine) error {
+		if n < 0 {
+			return fmt.Errorf("retained versions must not be negative: %d", n)
+		}
+		cfg.retainedVersions = n
+		return nil
+	}
+}
+
 // WithRandomization sets the randomization time in seconds
 // default is 10 minutes
 // if set, when scheduling the file pulling, it will add randomization time to the interval
@@ -182,6 +270,77 @@ func WithTempDataDir(dir string) EngineOptions {
 func WithRandomization(seconds int) EngineOptions {
 	return func(cfg *Engine) error {
 		cfg.SetRandomization(seconds * 1000)
+		cfg.randomizationMs = seconds * 1000
+		return nil
+	}
+}
+
+// WithBatchWorkers sets the number of worker goroutines used by ProcessBatch and ProcessStream
+// default is the number of CPUs, further limited by the concurrency of the configured ConfigIpi
+func WithBatchWorkers(workers int) EngineOptions {
+	return func(cfg *Engine) error {
+		if workers < 0 {
+			return fmt.Errorf("batch workers must not be negative: %d", workers)
+		}
+
+		cfg.batchWorkers = workers
+		return nil
+	}
+}
+
+// WithMemoryBudget sets the most memory, in bytes, the engine's data sets may need. Before the data
+// file is loaded, when the engine is created and on every reload, the memory it needs with the
+// configured ConfigIpi profile is estimated from its header, see ipi_interop.EstimateManagerSize;
+// the estimate is coarse, so leave some headroom.
+// The previous data set stays in memory until lookups using it have finished, so on a reload the
+// estimates of the live and the new data set are added together. The load is refused with
+// ErrMemoryBudgetExceeded if the estimate is over budget; a refused reload leaves the current data
+// set in use. Zero, the default, means no budget.
+func WithMemoryBudget(bytes uint64) EngineOptions {
+	return func(cfg *Engine) error {
+		cfg.memoryBudget = bytes
+		return nil
+	}
+}
+
+// WithOnReload adds a callback which is called after every load or reload of the data file,
+// including failed ones, with a ReloadEvent describing it. It is called from the goroutine doing
+// the reload, which may be the file watcher's or the file puller's and waits for it to return, so
+// the callback must return quickly and hand slow work off to another goroutine. It must not call
+// Engine.Stop or Engine.Shutdown, which wait for those goroutines to finish and so would never
+// return; call them from another goroutine instead. See also Engine.Subscribe.
+func WithOnReload(onReload func(ReloadEvent)) EngineOptions {
+	return func(cfg *Engine) error {
+		if onReload != nil {
+			cfg.onReload = append(cfg.onReload, onReload)
+		}
+		return nil
+	}
+}
+
+// WithGoldenIPs sets IP addresses every data file is checked against before it replaces the live
+// one, whether it was downloaded, changed on disk or loaded from memory. Each must match a range and
+// return some value, plus any values it expects. A data file which fails is rejected with an error
+// matching ErrDataFileRejected, reported in the ReloadEvent, and the live one keeps being used.
+// Creating the engine fails if its first data file is rejected.
+func WithGoldenIPs(ips ...GoldenIP) EngineOptions {
+	return func(cfg *Engine) error {
+		for _, golden := range ips {
+			if _, err := netip.ParseAddr(golden.IpAddress); err != nil {
+				return fmt.Errorf("invalid golden IP: %w", err)
+			}
+		}
+		cfg.goldenIPs = append(cfg.goldenIPs, ips...)
+		return nil
+	}
+}
+
+// WithMetrics instruments the engine: lookups, their outcomes and latency, reloads of the data file
+// and their duration, and the file puller's download attempts are reported to metrics. See
+// NewExpvarMetrics and NewPrometheusMetrics for adapters which publish them.
+func WithMetrics(metrics Metrics) EngineOptions {
+	return func(cfg *Engine) error {
+		cfg.metrics = metrics
 		return nil
 	}
 }
@@ -1,11 +1,16 @@
 package ipi_onpremise
 
 import (
-	common_go "github.com/51Degrees/common-go/v4"
+	"errors"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"testing/fstest"
+
+	common_go "github.com/51Degrees/common-go/v4"
+	"github.com/51Degrees/ip-intelligence-go/v4/ipi_interop"
 )
 
 func TestWithUpdateOnStart(t *testing.T) {
@@ -237,3 +242,85 @@ func TestWithProperties(t *testing.T) {
 		})
 	}
 }
+
+func TestWithBatchWorkers(t *testing.T) {
+	tests := []struct {
+		name        string
+		workers     int
+		expectError bool
+	}{
+		{name: "default workers", workers: 0, expectError: false},
+		{name: "4 workers", workers: 4, expectError: false},
+		{name: "negative workers", workers: -1, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := &Engine{}
+
+			option := WithBatchWorkers(tt.workers)
+			err := option(engine)
+
+			if tt.expectError {
+				if err == nil {
+					t.Error("expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if engine.batchWorkers != tt.workers {
+				t.Errorf("expected %d batch workers, got %d", tt.workers, engine.batchWorkers)
+			}
+		})
+	}
+}
+
+// errReader is an io.Reader which always fails.
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) {
+	return 0, errors.New("read failed")
+}
+
+func TestWithDataInMemory(t *testing.T) {
+	fsys := fstest.MapFS{"data/51Degrees.ipi": {Data: []byte("data")}}
+
+	tests := []struct {
+		name        string
+		option      EngineOptions
+		expectError error
+		expectData  string
+	}{
+		{name: "bytes", option: WithDataBytes([]byte("data")), expectData: "data"},
+		{name: "nil bytes", option: WithDataBytes(nil), expectError: ipi_interop.ErrCorruptData},
+		{name: "reader", option: WithDataReader(strings.NewReader("data")), expectData: "data"},
+		{name: "empty reader", option: WithDataReader(strings.NewReader("")), expectError: ipi_interop.ErrCorruptData},
+		{name: "failing reader", option: WithDataReader(errReader{}), expectError: errors.New("failed to read data file: read failed")},
+		{name: "fs", option: WithDataFS(fsys, "data/51Degrees.ipi"), expectData: "data"},
+		{name: "missing fs file", option: WithDataFS(fsys, "missing.ipi"), expectError: fs.ErrNotExist},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := &Engine{
+				FileUpdater: common_go.NewFileUpdater(""),
+			}
+
+			err := tt.option(engine)
+			if tt.expectError != nil {
+				if err == nil || (!errors.Is(err, tt.expectError) && err.Error() != tt.expectError.Error()) {
+					t.Errorf("expected error %v, got %v", tt.expectError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if string(engine.data) != tt.expectData {
+				t.Errorf("expected data %q, got %q", tt.expectData, engine.data)
+			}
+		})
+	}
+}
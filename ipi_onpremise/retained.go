@@ -0,0 +1,327 @@
+package ipi_onpremise
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrVersionNotRetained is returned by Rollback, LoadVersion and LoadRetainedVersion when the data
+// file version asked for is not one of those kept with WithRetainedVersions.
+var ErrVersionNotRetained = errors.New("data file version not retained")
+
+// errRetainedVersionsNeedTempCopy is returned by New when WithRetainedVersions is given for an engine
+// without temp data copies, which has no temp data directory to keep them in.
+var errRetainedVersionsNeedTempCopy = errors.New("retained data file versions need temp data copies, see WithTempDataCopy")
+
+// retainedVersionLayout is the layout of the published date in the name of a retained data file.
+// Data files are published on a date, without a time.
+const retainedVersionLayout = "20060102"
+
+// RetainedVersion identifies a data file kept with WithRetainedVersions. A data file can be
+// re-issued on the day it was published, so versions are told apart by when the data file they were
+// loaded from was last modified as well as their published date.
+type RetainedVersion struct {
+	Published time.Time // Date the data file was published
+	Modified  time.Time // Modification time of the data file it was loaded from
+}
+
+// retainedVersion is a data file kept in the temp data directory, see WithRetainedVersions.
+type retainedVersion struct {
+	RetainedVersion
+	path string
+	size int64
+	// seq orders versions by when they were first retained, from 1. It is 0 for a live data file
+	// which could not be retained.
+	seq uint64
+}
+
+// before returns true if v is older than other: published earlier, or first retained earlier on
+// the same day.
+func (v retainedVersion) before(other retainedVersion) bool {
+	if !v.Published.Equal(other.Published) {
+		return v.Published.Before(other.Published)
+	}
+	return v.seq < other.seq
+}
+
+// is returns true if v is the version identified by version.
+func (v retainedVersion) is(version RetainedVersion) bool {
+	return v.Published.Equal(version.Published) && v.Modified.Equal(version.Modified)
+}
+
+// holds returns true if v holds the data file at path, of size bytes, published on published and
+// modified at modified. Only a data file published on the same day with the same size can be the
+// same, and one also modified at the same time is taken to be; otherwise the contents are compared.
+func (v retainedVersion) holds(path string, size int64, published, modified time.Time) bool {
+	if !v.Published.Equal(published) || v.size != size {
+		return false
+	}
+	if v.Modified.Equal(modified) {
+		return true
+	}
+	same, err := sameContents(v.path, path)
+	return err == nil && same
+}
+
+// retainedVersionPath returns the path the version is kept at, alongside the temp copy at tempPath,
+// e.g. 51Degrees-20250304-2.ipi, where 2 is its seq.
+func (e *Engine) retainedVersionPath(tempPath string, version retainedVersion) string {
+	base := filepath.Base(e.GetDataFile())
+	ext := filepath.Ext(base)
+	name := fmt.Sprintf("%s-%s-%d%s", strings.TrimSuffix(base, ext),
+		version.Published.UTC().Format(retainedVersionLayout), version.seq, ext)
+	return filepath.Join(filepath.Dir(tempPath), name)
+}
+
+// keepModTime gives the temp copy at tempPath the modification time of the data file it was copied
+// from, so that retainVersion can tell data files apart without reading them. modified must be
+// read before the copy is made, so the copy is never given the time of a newer data file. It does
+// nothing unless WithRetainedVersions was given.
+func (e *Engine) keepModTime(tempPath string, modified time.Time) {
+	if e.retainedVersions <= 0 {
+		return
+	}
+	if err := os.Chtimes(tempPath, modified, modified); err != nil {
+		e.log(slog.LevelWarn, "failed to set the modification time of the temp data file", "path", tempPath, "error", err)
+	}
+}
+
+// retainVersion keeps the temp copy at tempPath, which the live data set was just loaded from, as a
+// retained version, and removes the oldest versions beyond those WithRetainedVersions keeps. It
+// does nothing unless WithRetainedVersions was given.
+func (e *Engine) retainVersion(tempPath string, published time.Time) {
+	if e.retainedVersions <= 0 {
+		return
+	}
+
+	e.versionsMu.Lock()
+	defer e.versionsMu.Unlock()
+
+	info, err := os.Stat(tempPath)
+	if err != nil {
+		e.live = retainedVersion{RetainedVersion: RetainedVersion{Published: published}}
+		e.log(slog.LevelWarn, "failed to retain data file", "path", tempPath, "published", published, "error", err)
+		return
+	}
+	for i, retained := range e.versions {
+		if retained.holds(tempPath, info.Size(), published, info.ModTime()) {
+			// Already kept, e.g. when it was loaded with LoadVersion; it is now the latest loaded.
+			e.live = retained
+			e.versions = append(append(e.versions[:i:i], e.versions[i+1:]...), retained)
+			return
+		}
+	}
+
+	version := retainedVersion{
+		RetainedVersion: RetainedVersion{Published: published, Modified: info.ModTime().UTC()},
+		size:            info.Size(),
+		seq:             e.versionSeq + 1,
+	}
+	version.path = e.retainedVersionPath(tempPath, version)
+	if err := linkOrCopy(tempPath, version.path); err != nil {
+		e.live = retainedVersion{RetainedVersion: version.RetainedVersion}
+		e.log(slog.LevelWarn, "failed to retain data file", "path", version.path, "published", published, "error", err)
+		return
+	}
+	e.versionSeq++
+	e.live = version
+	e.versions = append(e.versions, e.live)
+
+	// The live version is the last, so the first are the least recently loaded.
+	for len(e.versions) > e.retainedVersions+1 {
+		oldest := e.versions[0]
+		e.versions = e.versions[1:]
+		if err := os.Remove(oldest.path); err != nil && !os.IsNotExist(err) {
+			e.log(slog.LevelWarn, "failed to remove retained data file", "path", oldest.path, "error", err)
+		}
+	}
+}
+
+// RetainedVersions returns the data files kept with WithRetainedVersions, including the live one,
+// oldest first: by published date, then by when they were first retained. Any of them can be loaded
+// with LoadRetainedVersion.
+func (e *Engine) RetainedVersions() []RetainedVersion {
+	e.versionsMu.Lock()
+	versions := append([]retainedVersion(nil), e.versions...)
+	e.versionsMu.Unlock()
+
+	sort.Slice(versions, func(i, j int) bool { return versions[i].before(versions[j]) })
+	retained := make([]RetainedVersion, len(versions))
+	for i, version := range versions {
+		retained[i] = version.RetainedVersion
+	}
+	return retained
+}
+
+// Rollback replaces the live data set with the newest retained data file older than it, in the
+// order of RetainedVersions, see WithRetainedVersions, for example when an update has reached
+// production with bad data. An error matching ErrVersionNotRetained is returned if there is none.
+// The data file on disk is left alone, so the file watcher or puller reload a newer one once it
+// changes.
+func (e *Engine) Rollback() error {
+	e.versionsMu.Lock()
+	var previous *retainedVersion
+	for i, version := range e.versions {
+		if version.before(e.live) && (previous == nil || previous.before(version)) {
+			previous = &e.versions[i]
+		}
+	}
+	var version RetainedVersion
+	if previous != nil {
+		version = previous.RetainedVersion
+	}
+	live := e.live.Published
+	e.versionsMu.Unlock()
+
+	if previous == nil {
+		return fmt.Errorf("%w: none older than the live data file published %s", ErrVersionNotRetained, live.Format(time.RFC3339))
+	}
+	return e.LoadRetainedVersion(version)
+}
+
+// LoadVersion replaces the live data set with the retained data file published on published, see
+// WithRetainedVersions. If more than one data file published that day is retained, the one most
+// recently retained is loaded; use LoadRetainedVersion to load another. An error matching
+// ErrVersionNotRetained is returned if none is retained. It is checked like any other data file
+// before it is used, see ErrDataFileRejected.
+func (e *Engine) LoadVersion(published time.Time) error {
+	e.versionsMu.Lock()
+	var path string
+	var seq uint64
+	for _, retained := range e.versions {
+		if retained.Published.Equal(published) && retained.seq > seq {
+			path, seq = retained.path, retained.seq
+		}
+	}
+	e.versionsMu.Unlock()
+
+	if path == "" {
+		return fmt.Errorf("%w: published %s", ErrVersionNotRetained, published.Format(time.RFC3339))
+	}
+	return e.loadRetained(path)
+}
+
+// LoadRetainedVersion replaces the live data set with the retained data file version, one of those
+// returned by RetainedVersions, which tells apart data files re-issued on the day they were
+// published. An error matching ErrVersionNotRetained is returned if it is not retained. It is
+// checked like any other data file before it is used, see ErrDataFileRejected.
+func (e *Engine) LoadRetainedVersion(version RetainedVersion) error {
+	e.versionsMu.Lock()
+	var path string
+	for _, retained := range e.versions {
+		if retained.is(version) {
+			path = retained.path
+		}
+	}
+	e.versionsMu.Unlock()
+
+	if path == "" {
+		return fmt.Errorf("%w: published %s and modified %s", ErrVersionNotRetained,
+			version.Published.Format(time.RFC3339), version.Modified.Format(time.RFC3339))
+	}
+	return e.loadRetained(path)
+}
+
+// loadRetained replaces the live data set with the retained data file at path.
+func (e *Engine) loadRetained(path string) error {
+	return e.reload(ReloadTriggerManual, path, func() error {
+		// The manager is loaded from a temp copy, like any other, so that the retained file can be
+		// removed while the manager still uses it.
+		tempPath, err := e.tempCopyOf(path)
+		if err != nil {
+			return err
+		}
+		return e.reloadManager(tempPath)
+	})
+}
+
+// tempCopyOf returns a new temp copy of the retained data file at path, in the same directory.
+func (e *Engine) tempCopyOf(path string) (string, error) {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(e.GetDataFile()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp data file: %w", err)
+	}
+	tempPath := f.Name()
+	f.Close()
+	os.Remove(tempPath)
+
+	if err := linkOrCopy(path, tempPath); err != nil {
+		return "", err
+	}
+	return tempPath, nil
+}
+
+// sameContents returns true if the files at a and b have the same contents. It stops reading at the
+// first difference.
+func sameContents(a, b string) (bool, error) {
+	fa, err := os.Open(a)
+	if err != nil {
+		return false, err
+	}
+	defer fa.Close()
+	fb, err := os.Open(b)
+	if err != nil {
+		return false, err
+	}
+	defer fb.Close()
+
+	bufA, bufB := make([]byte, 64*1024), make([]byte, 64*1024)
+	for {
+		n, errA := io.ReadFull(fa, bufA)
+		m, errB := io.ReadFull(fb, bufB)
+		if !bytes.Equal(bufA[:n], bufB[:m]) {
+			return false, nil
+		}
+		endA := errA == io.EOF || errA == io.ErrUnexpectedEOF
+		endB := errB == io.EOF || errB == io.ErrUnexpectedEOF
+		if endA || endB {
+			return endA && endB, nil
+		}
+		if errA != nil {
+			return false, errA
+		}
+		if errB != nil {
+			return false, errB
+		}
+	}
+}
+
+// linkOrCopy makes dst a hard link to src, so large data files are not copied, or a copy of src
+// where the file system does not support hard links.
+func linkOrCopy(src, dst string) error {
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to read data file: %w", err)
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("failed to create data file: %w", err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return fmt.Errorf("failed to copy data file: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	// A hard link shares the modification time, which retainVersion tells data files apart by.
+	if info, err := in.Stat(); err == nil {
+		os.Chtimes(dst, info.ModTime(), info.ModTime())
+	}
+	return nil
+}
@@ -0,0 +1,249 @@
+package ipi_onpremise
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newRetainingEngine returns an engine keeping n previous data files, whose temp copies are in dir.
+func newRetainingEngine(t *testing.T, n int) (*Engine, string) {
+	t.Helper()
+	engine := newStoppableEngine()
+	engine.SetDataFile("/data/51Degrees.ipi")
+	if err := WithRetainedVersions(n)(engine); err != nil {
+		t.Fatalf("WithRetainedVersions() error = %v", err)
+	}
+	return engine, t.TempDir()
+}
+
+// loadTempCopy writes a temp copy of a data file published on published and modified at modified
+// with the contents to dir, and retains it as if it had been loaded.
+func loadTempCopy(t *testing.T, engine *Engine, dir string, published, modified time.Time, contents string) string {
+	t.Helper()
+	f, err := os.CreateTemp(dir, "51Degrees.ipi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(contents); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	engine.keepModTime(f.Name(), modified)
+	engine.retainVersion(f.Name(), published)
+	return f.Name()
+}
+
+// retainedPath returns the path the data file published on published and retained seq-th is kept at.
+func retainedPath(dir string, published time.Time, seq int) string {
+	return filepath.Join(dir, fmt.Sprintf("51Degrees-%s-%d.ipi", published.Format("20060102"), seq))
+}
+
+func TestEngine_retainVersion(t *testing.T) {
+	engine, dir := newRetainingEngine(t, 1)
+	first := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	second := first.AddDate(0, 0, 7)
+	third := second.AddDate(0, 0, 7)
+
+	tempPath := loadTempCopy(t, engine, dir, first, first, "first")
+	// The temp copy is removed once its manager is freed, but the retained version is kept.
+	os.Remove(tempPath)
+	retained := retainedPath(dir, first, 1)
+	if data, err := os.ReadFile(retained); err != nil || string(data) != "first" {
+		t.Fatalf("Expected the data file to be retained as %s, got %q, %v", retained, data, err)
+	}
+
+	loadTempCopy(t, engine, dir, second, second, "second")
+	loadTempCopy(t, engine, dir, third, third, "third")
+
+	got := engine.RetainedVersions()
+	want := []RetainedVersion{{Published: second, Modified: second}, {Published: third, Modified: third}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected the live and 1 previous version %v, got %v", want, got)
+	}
+	if _, err := os.Stat(retained); !os.IsNotExist(err) {
+		t.Errorf("Expected the oldest version to be removed, got %v", err)
+	}
+}
+
+func TestEngine_retainVersion_SameDay(t *testing.T) {
+	engine, dir := newRetainingEngine(t, 2)
+	published := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	issued, reissued := published.Add(time.Hour), published.Add(2*time.Hour)
+
+	// The data files are the same size, so their contents are compared.
+	loadTempCopy(t, engine, dir, published, issued, "issued-1")
+	loadTempCopy(t, engine, dir, published, reissued, "issued-2")
+	// The same data file downloaded again is the version already kept.
+	loadTempCopy(t, engine, dir, published, reissued.Add(time.Hour), "issued-2")
+
+	got := engine.RetainedVersions()
+	want := []RetainedVersion{{Published: published, Modified: issued}, {Published: published, Modified: reissued}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected both data files published on the same day %v, got %v", want, got)
+	}
+	for seq, contents := range []string{"issued-1", "issued-2"} {
+		if data, err := os.ReadFile(retainedPath(dir, published, seq+1)); err != nil || string(data) != contents {
+			t.Errorf("Expected %q to be retained, got %q, %v", contents, data, err)
+		}
+	}
+
+	var event ReloadEvent
+	engine.onReload = append(engine.onReload, func(e ReloadEvent) { event = e })
+
+	// The retained files are not real data files, so loading one fails, but it is attempted.
+	tests := []struct {
+		name string
+		load func() error
+		want string
+	}{
+		{name: "rollback", load: engine.Rollback, want: retainedPath(dir, published, 1)},
+		{name: "latest of the day", load: func() error { return engine.LoadVersion(published) }, want: retainedPath(dir, published, 2)},
+		{name: "version", load: func() error { return engine.LoadRetainedVersion(want[0]) }, want: retainedPath(dir, published, 1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.load(); err == nil || errors.Is(err, ErrVersionNotRetained) {
+				t.Fatalf("Expected a failed load, got %v", err)
+			}
+			if event.FilePath != tt.want {
+				t.Errorf("Expected a reload of %s, got %+v", tt.want, event)
+			}
+		})
+	}
+}
+
+func TestEngine_Rollback_NotRetained(t *testing.T) {
+	engine, dir := newRetainingEngine(t, 2)
+	published := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	if err := engine.Rollback(); !errors.Is(err, ErrVersionNotRetained) {
+		t.Errorf("Rollback() without versions error = %v, want %v", err, ErrVersionNotRetained)
+	}
+
+	loadTempCopy(t, engine, dir, published, published, "first")
+	if err := engine.Rollback(); !errors.Is(err, ErrVersionNotRetained) {
+		t.Errorf("Rollback() from the oldest version error = %v, want %v", err, ErrVersionNotRetained)
+	}
+	if err := engine.LoadVersion(published.AddDate(0, 0, 1)); !errors.Is(err, ErrVersionNotRetained) {
+		t.Errorf("LoadVersion() of an unknown date error = %v, want %v", err, ErrVersionNotRetained)
+	}
+	other := RetainedVersion{Published: published, Modified: published.Add(time.Hour)}
+	if err := engine.LoadRetainedVersion(other); !errors.Is(err, ErrVersionNotRetained) {
+		t.Errorf("LoadRetainedVersion() of an unknown version error = %v, want %v", err, ErrVersionNotRetained)
+	}
+}
+
+func TestEngine_Rollback_LoadsPrevious(t *testing.T) {
+	engine, dir := newRetainingEngine(t, 2)
+	first := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	second := first.AddDate(0, 0, 7)
+	loadTempCopy(t, engine, dir, first, first, "first")
+	loadTempCopy(t, engine, dir, second, second, "second")
+
+	var event ReloadEvent
+	engine.onReload = append(engine.onReload, func(e ReloadEvent) { event = e })
+
+	// The retained files are not real data files, so loading one fails, but it is attempted.
+	if err := engine.Rollback(); err == nil || errors.Is(err, ErrVersionNotRetained) {
+		t.Fatalf("Rollback() error = %v, want a failed load", err)
+	}
+	if event.Trigger != ReloadTriggerManual || event.FilePath != retainedPath(dir, first, 1) {
+		t.Errorf("Expected a manual reload of the first version, got %+v", event)
+	}
+
+	// The temp copy made to load it is removed, leaving only the retained versions.
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	retained := 0
+	for _, entry := range entries {
+		if filepath.Ext(entry.Name()) == ".ipi" {
+			retained++
+		}
+	}
+	if retained != 2 {
+		t.Errorf("Expected 2 retained versions, got %v", entries)
+	}
+}
+
+func TestSameContents(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, contents string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	large := strings.Repeat("x", 100*1024)
+
+	tests := []struct {
+		name string
+		a, b string
+		want bool
+	}{
+		{name: "same", a: "data", b: "data", want: true},
+		{name: "different", a: "data", b: "date"},
+		{name: "prefix", a: "data", b: "data1"},
+		{name: "same large", a: large, b: large, want: true},
+		{name: "different large", a: large + "a", b: large + "b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			same, err := sameContents(write("a", tt.a), write("b", tt.b))
+			if err != nil || same != tt.want {
+				t.Errorf("sameContents() = %v, %v, want %v", same, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLinkOrCopy(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.ipi")
+	if err := os.WriteFile(src, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := filepath.Join(dir, "dst.ipi")
+	if err := linkOrCopy(src, dst); err != nil {
+		t.Fatalf("linkOrCopy() error = %v", err)
+	}
+	os.Remove(src)
+	if data, err := os.ReadFile(dst); err != nil || string(data) != "data" {
+		t.Errorf("Expected the copy to outlive the source, got %q, %v", data, err)
+	}
+
+	if err := linkOrCopy(filepath.Join(dir, "missing.ipi"), filepath.Join(dir, "other.ipi")); err == nil {
+		t.Error("Expected an error for a missing source")
+	}
+}
+
+func TestNew_RetainedVersionsNeedTempCopy(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []EngineOptions
+	}{
+		{name: "memory", options: []EngineOptions{WithDataBytes([]byte("data")), WithRetainedVersions(1)}},
+		{name: "no temp copy", options: []EngineOptions{WithDataFile(createTestDataFile(t)), WithTempDataCopy(false), WithRetainedVersions(1)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.options...); !errors.Is(err, errRetainedVersionsNeedTempCopy) {
+				t.Errorf("New() error = %v, want %v", err, errRetainedVersionsNeedTempCopy)
+			}
+		})
+	}
+
+	if err := WithRetainedVersions(-1)(&Engine{}); err == nil {
+		t.Error("Expected an error for a negative number of versions")
+	}
+}
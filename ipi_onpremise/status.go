@@ -0,0 +1,157 @@
+package ipi_onpremise
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/51Degrees/ip-intelligence-go/v4/ipi_interop"
+)
+
+// Status is a snapshot of the state of the engine, see Engine.Status.
+type Status struct {
+	// Ready is true when a data file is loaded and lookups can be made
+	Ready bool `json:"ready"`
+	// FileWatcherRunning is true when the data file is being watched for changes
+	FileWatcherRunning bool `json:"fileWatcherRunning"`
+	// FilePullerRunning is true when updated data files are being pulled, see WithAutoUpdate
+	FilePullerRunning bool `json:"filePullerRunning"`
+
+	// LastUpdateAttempt is when the last load or reload of the data file started, zero if there was none
+	LastUpdateAttempt time.Time `json:"lastUpdateAttempt"`
+	// LastUpdateTrigger is what caused the last load or reload, see ReloadTrigger
+	LastUpdateTrigger string `json:"lastUpdateTrigger,omitempty"`
+	// LastUpdateError is why the last load or reload failed, empty if it succeeded
+	LastUpdateError string `json:"lastUpdateError,omitempty"`
+	// ConsecutiveFailures is the number of loads or reloads which have failed since the last one which succeeded
+	ConsecutiveFailures int `json:"consecutiveFailures"`
+
+	// NextPoll is when the file puller is next expected to pull the data file, zero if it is not
+	// running. It is estimated from the polling interval and randomization; the puller retries
+	// sooner after a failed pull, and a server may ask it to wait longer.
+	NextPoll time.Time `json:"nextPoll"`
+	// ExternalChanges is the number of changes to the data file seen by the file watcher
+	ExternalChanges int `json:"externalChanges"`
+
+	// Published is the published date of the live data file, zero if none is loaded
+	Published time.Time `json:"published"`
+	// DataAge is how long ago the live data file was published, in nanoseconds in JSON
+	DataAge time.Duration `json:"dataAge"`
+}
+
+// engineStatus holds the parts of Status the engine has to track itself, as the FileUpdater does
+// not expose them.
+type engineStatus struct {
+	mu sync.Mutex
+
+	lastUpdateAttempt   time.Time
+	lastUpdateTrigger   ReloadTrigger
+	lastUpdateErr       error
+	consecutiveFailures int
+	// filePullerStarted is when the file puller was started, zero if it was not
+	filePullerStarted time.Time
+	externalChanges   int
+}
+
+// recordReload records the outcome of a load or reload of the data file, which started at start.
+func (s *engineStatus) recordReload(start time.Time, event ReloadEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastUpdateAttempt = start
+	s.lastUpdateTrigger = event.Trigger
+	s.lastUpdateErr = event.Err
+	if event.Err != nil {
+		s.consecutiveFailures++
+	} else {
+		s.consecutiveFailures = 0
+	}
+}
+
+// recordFilePullerStarted records that the file puller was started at start.
+func (s *engineStatus) recordFilePullerStarted(start time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.filePullerStarted = start
+}
+
+// recordExternalChange counts a change to the data file seen by the file watcher.
+func (s *engineStatus) recordExternalChange() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.externalChanges++
+}
+
+// nextPoll estimates when a file puller started at started, pulling every period, next pulls
+// after now.
+func nextPoll(started time.Time, period time.Duration, now time.Time) time.Time {
+	if started.IsZero() || period <= 0 {
+		return time.Time{}
+	}
+	if now.Before(started) {
+		return started.Add(period)
+	}
+	return started.Add((now.Sub(started)/period + 1) * period)
+}
+
+// Status returns a snapshot of the state of the engine: whether it is ready, which of the file
+// watcher and file puller are running, how the last load or reload of the data file went, and how
+// old the live data is. It does not wait for a reload in progress to finish.
+func (e *Engine) Status() Status {
+	stopped := e.isStopped.Load()
+	status := Status{
+		FileWatcherRunning: !stopped && e.IsFileWatcherEnabled() && e.IsFileWatcherStarted(),
+		FilePullerRunning:  !stopped && e.IsAutoUpdateEnabled() && e.IsFilePullerStarted(),
+	}
+
+	now := time.Now()
+	if manager := e.acquireManager(); manager != nil {
+		status.Published = ipi_interop.GetPublishedDate(manager)
+		manager.Release()
+		status.Ready = !stopped
+		status.DataAge = now.Sub(status.Published)
+	}
+
+	e.status.mu.Lock()
+	defer e.status.mu.Unlock()
+	status.LastUpdateAttempt = e.status.lastUpdateAttempt
+	if !status.LastUpdateAttempt.IsZero() {
+		status.LastUpdateTrigger = e.status.lastUpdateTrigger.String()
+	}
+	if e.status.lastUpdateErr != nil {
+		status.LastUpdateError = e.status.lastUpdateErr.Error()
+	}
+	status.ConsecutiveFailures = e.status.consecutiveFailures
+	status.ExternalChanges = e.status.externalChanges
+	if status.FilePullerRunning {
+		period := time.Duration(e.GetDataFilePullEveryMs()+e.randomizationMs) * time.Millisecond
+		status.NextPoll = nextPoll(e.status.filePullerStarted, period, now)
+	}
+	return status
+}
+
+// StatusHandler returns an http.Handler which serves the engine's Status as JSON, for use as a
+// Kubernetes readiness or liveness probe. It responds 200 OK while the engine is ready and 503
+// Service Unavailable otherwise. A failed reload does not make the engine unready, as it keeps
+// serving the data file it already has; check ConsecutiveFailures or DataAge to alert on that.
+func (e *Engine) StatusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := e.Status()
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if status.Ready {
+			w.WriteHeader(http.StatusOK)
+		} else {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		if r.Method == http.MethodHead {
+			return
+		}
+		if err := json.NewEncoder(w).Encode(status); err != nil {
+			e.log(slog.LevelWarn, "failed to write engine status", "error", err)
+		}
+	})
+}
@@ -0,0 +1,70 @@
+package ipi_onpremise
+
+import (
+	"errors"
+	"fmt"
+
+	common_go "github.com/51Degrees/common-go/v4"
+)
+
+// The messages the FileUpdater's file puller logs which end a pull attempt. The puller has no other
+// hook, so the engine recognises them to observe downloads, see updaterLogWriter. They must be kept
+// the same as the version of common-go in go.mod, which TestPullFormats_MatchCommonGo checks.
+const (
+	pullFailedFormat      = "failed to pull data file: %v"
+	pullFileInfoFormat    = "failed to get file info: %v"
+	pullWriteFailedFormat = "failed to write data file: %v"
+	pullWrittenFormat     = "data file written successfully: %d bytes"
+)
+
+// updaterLogWriter is the LogWriter the engine installs in its FileUpdater. It passes messages on to
+// the logger set with WithSlogLogger, as structured events, or the writer set with
+// WithCustomLogger, unless logging is disabled with WithLogging. It also observes the outcome of
+// each attempt of the file puller to download a data file.
+type updaterLogWriter struct {
+	engine *Engine
+}
+
+func (w updaterLogWriter) Printf(format string, v ...interface{}) {
+	switch format {
+	case pullWrittenFormat:
+		w.engine.observeDownload(nil)
+	case pullFailedFormat, pullFileInfoFormat, pullWriteFailedFormat:
+		w.engine.observeDownload(pullError(format, v))
+	}
+
+	switch {
+	case w.engine.loggingDisabled:
+	case w.engine.slog != nil:
+		w.engine.logUpdater(format, v)
+	default:
+		w.engine.logWriter.Printf(format, v...)
+	}
+}
+
+// pullError returns the error logged with a failed pull attempt.
+func pullError(format string, v []interface{}) error {
+	if len(v) == 1 {
+		if err, ok := v[0].(error); ok {
+			return err
+		}
+	}
+	return errors.New(fmt.Sprintf(format, v...))
+}
+
+// observeDownload reports an attempt of the file puller to download a data file to the engine's
+// metrics, if any.
+func (e *Engine) observeDownload(err error) {
+	if e.metrics != nil {
+		e.metrics.ObserveDownload(err)
+	}
+}
+
+// installLogWriter routes the messages of the engine and its FileUpdater through an
+// updaterLogWriter, once the options have set the writer and whether logging is enabled.
+func (e *Engine) installLogWriter() {
+	if e.logWriter == nil {
+		e.logWriter = common_go.DefaultLogger
+	}
+	e.logger = e.SetCustomLogger(updaterLogWriter{engine: e})
+}
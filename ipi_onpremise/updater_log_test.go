@@ -0,0 +1,42 @@
+package ipi_onpremise
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestPullFormats_MatchCommonGo checks that the file puller of the common-go version in go.mod still
+// logs the messages the engine recognises to observe downloads, as they are not part of its API.
+func TestPullFormats_MatchCommonGo(t *testing.T) {
+	out, err := exec.Command("go", "list", "-m", "-f", "{{.Dir}}", "github.com/51Degrees/common-go/v4").Output()
+	dir := strings.TrimSpace(string(out))
+	if err != nil || dir == "" {
+		t.Skipf("The source of common-go is not available: %v", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var source strings.Builder
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		source.Write(data)
+	}
+
+	for _, format := range []string{pullFailedFormat, pullFileInfoFormat, pullWriteFailedFormat, pullWrittenFormat} {
+		if !strings.Contains(source.String(), "e.logger.Printf("+strconv.Quote(format)) {
+			t.Errorf("Expected the file puller in %s to log %q", dir, format)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package ipi_onpremise
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/51Degrees/ip-intelligence-go/v4/ipi_interop"
+)
+
+// ErrDataFileRejected is returned, and reported in the ReloadEvent, when a data file loads but fails
+// the checks made before it replaces the live one: its header must give a valid published date, it
+// must have every property given with WithProperties, and the IP addresses given with
+// WithGoldenIPs must give sane results. The live data file keeps being used.
+var ErrDataFileRejected = errors.New("data file rejected")
+
+// GoldenIP is an IP address a data file is checked against before it replaces the live one, see
+// WithGoldenIPs.
+type GoldenIP struct {
+	IpAddress string
+	// Expect are values the lookup must return, compared as strings, e.g. {"RegisteredCountry": "GB"}.
+	// With none, the lookup only has to match a range and return some value.
+	Expect map[string]string
+}
+
+// validateManager checks a newly loaded manager before it replaces the live one, returning an error
+// matching ErrDataFileRejected if it fails.
+func (e *Engine) validateManager(manager *ipi_interop.ResourceManager) error {
+	if _, err := ipi_interop.GetValidPublishedDate(manager); err != nil {
+		return fmt.Errorf("%w: the header has no valid published date: %v", ErrDataFileRejected, err)
+	}
+
+	results := ipi_interop.NewResultsIpi(manager)
+	defer results.Free()
+
+	if missing := missingProperties(e.managerProperties, results.AvailablePropertyNames()); len(missing) > 0 {
+		return fmt.Errorf("%w: requested properties missing: %s", ErrDataFileRejected, strings.Join(missing, ", "))
+	}
+
+	if len(e.goldenIPs) == 0 {
+		return nil
+	}
+	caches := e.initPropertyIndexesWithIndexer(results)
+	for _, golden := range e.goldenIPs {
+		if err := results.ResultsIpiFromIpAddress(golden.IpAddress); err != nil {
+			return fmt.Errorf("%w: lookup of golden IP %s failed: %v", ErrDataFileRejected, golden.IpAddress, err)
+		}
+		if !results.HasValues() {
+			return fmt.Errorf("%w: golden IP %s matched no range", ErrDataFileRejected, golden.IpAddress)
+		}
+		values, err := results.GetWeightedValuesByIndexes(caches.propertyIndexes, caches.propertyName)
+		if err != nil {
+			return fmt.Errorf("%w: lookup of golden IP %s failed: %v", ErrDataFileRejected, golden.IpAddress, err)
+		}
+		if err := checkGoldenValues(golden, values); err != nil {
+			return fmt.Errorf("%w: %v", ErrDataFileRejected, err)
+		}
+	}
+	return nil
+}
+
+// missingProperties returns the requested properties which are not available, in order.
+func missingProperties(requested, available []string) []string {
+	found := make(map[string]bool, len(available))
+	for _, name := range available {
+		found[name] = true
+	}
+	var missing []string
+	for _, name := range requested {
+		if !found[name] {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// checkGoldenValues checks the values a golden IP returned: there must be at least one, and they
+// must include every expected value.
+func checkGoldenValues(golden GoldenIP, values ipi_interop.Values) error {
+	hasValue := false
+	for _, property := range values {
+		if len(property) > 0 {
+			hasValue = true
+			break
+		}
+	}
+	if !hasValue {
+		return fmt.Errorf("golden IP %s returned no values", golden.IpAddress)
+	}
+
+	// Sorted, so the same mismatch is always reported first.
+	properties := make([]string, 0, len(golden.Expect))
+	for property := range golden.Expect {
+		properties = append(properties, property)
+	}
+	sort.Strings(properties)
+	for _, property := range properties {
+		want := golden.Expect[property]
+		got, ok := values.GetValueByProperty(property)
+		if !ok {
+			return fmt.Errorf("golden IP %s returned no %s, want %q", golden.IpAddress, property, want)
+		}
+		if fmt.Sprint(got) != want {
+			return fmt.Errorf("golden IP %s returned %s %q, want %q", golden.IpAddress, property, fmt.Sprint(got), want)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package ipi_onpremise
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/51Degrees/ip-intelligence-go/v4/ipi_interop"
+)
+
+func TestMissingProperties(t *testing.T) {
+	available := []string{"RegisteredCountry", "RegisteredName", "Latitude"}
+
+	tests := []struct {
+		name      string
+		requested []string
+		want      string
+	}{
+		{name: "all properties", requested: nil, want: ""},
+		{name: "all available", requested: []string{"Latitude", "RegisteredCountry"}, want: ""},
+		{name: "missing", requested: []string{"Longitude", "RegisteredName", "AccuracyRadius"}, want: "Longitude,AccuracyRadius"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := strings.Join(missingProperties(tt.requested, available), ","); got != tt.want {
+				t.Errorf("missingProperties() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckGoldenValues(t *testing.T) {
+	values := ipi_interop.Values{}
+	values.AppendWithWeight("RegisteredCountry", "GB", 1)
+	values.AppendWithWeight("Latitude", 51.5, 1)
+	values.InitProperty("RegisteredName")
+
+	empty := ipi_interop.Values{}
+	empty.InitProperty("RegisteredName")
+
+	tests := []struct {
+		name    string
+		golden  GoldenIP
+		values  ipi_interop.Values
+		wantErr string
+	}{
+		{name: "any value", golden: GoldenIP{IpAddress: "1.1.1.1"}, values: values},
+		{name: "expected values", golden: GoldenIP{IpAddress: "1.1.1.1", Expect: map[string]string{"RegisteredCountry": "GB", "Latitude": "51.5"}}, values: values},
+		{name: "no values", golden: GoldenIP{IpAddress: "1.1.1.1"}, values: empty, wantErr: "returned no values"},
+		{name: "wrong value", golden: GoldenIP{IpAddress: "1.1.1.1", Expect: map[string]string{"RegisteredCountry": "US"}}, values: values, wantErr: `returned RegisteredCountry "GB", want "US"`},
+		{name: "missing value", golden: GoldenIP{IpAddress: "1.1.1.1", Expect: map[string]string{"RegisteredName": "BT"}}, values: values, wantErr: `returned no RegisteredName, want "BT"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkGoldenValues(tt.golden, tt.values)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("checkGoldenValues() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("checkGoldenValues() error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWithGoldenIPs(t *testing.T) {
+	engine := &Engine{}
+
+	if err := WithGoldenIPs(GoldenIP{IpAddress: "8.8.8.8"}, GoldenIP{IpAddress: "2001:4860:4860::8888"})(engine); err != nil {
+		t.Fatalf("WithGoldenIPs() error = %v", err)
+	}
+	if len(engine.goldenIPs) != 2 {
+		t.Errorf("Expected 2 golden IPs, got %d", len(engine.goldenIPs))
+	}
+	if err := WithGoldenIPs(GoldenIP{IpAddress: "not an ip"})(engine); err == nil {
+		t.Error("Expected an error for an invalid golden IP")
+	}
+}
+
+// publishedOffset is where the published date is in the header of a data file, after its packed
+// version, tags, copyright offset, age, placeholder, and name and format offsets.
+const publishedOffset = 66
+
+func TestNew_InvalidPublishedDate(t *testing.T) {
+	data := readTestDataFile(t)
+
+	engine, err := New(WithDataBytes(data))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	info, err := engine.DatasetInfo()
+	engine.Stop()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	published := data[publishedOffset : publishedOffset+4]
+	if int(binary.LittleEndian.Uint16(published)) != info.Published.Year() ||
+		time.Month(published[2]) != info.Published.Month() || int(published[3]) != info.Published.Day() {
+		t.Fatalf("Expected the published date %v at offset %d, got %v", info.Published, publishedOffset, published)
+	}
+
+	tests := []struct {
+		name string
+		date []byte
+	}{
+		{name: "zeroed", date: []byte{0, 0, 0, 0}},
+		{name: "month out of range", date: []byte{published[0], published[1], 13, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			corrupt := bytes.Clone(data)
+			copy(corrupt[publishedOffset:], tt.date)
+
+			engine, err := New(WithDataBytes(corrupt))
+			if !errors.Is(err, ErrDataFileRejected) {
+				t.Errorf("Expected %v, got %v", ErrDataFileRejected, err)
+			}
+			if engine != nil {
+				engine.Stop()
+			}
+		})
+	}
+}